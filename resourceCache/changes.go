@@ -0,0 +1,321 @@
+package resourcecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ChangeTracker remembers, across reconciles, what ApplyAll last wrote and which objects each owner
+// had in the cache at its last Reconcile. An ObjectCache only lives for a single reconcile, so one
+// ChangeTracker is created when the operator starts and passed to every cache in
+// Options.ChangeTracker. It is safe for concurrent use.
+//
+// ApplyAll skips an existing object without diffing it when its desired content hashes to the
+// value last written and its live resourceVersion, as read by Create, is the one last seen. With
+// Options.Reader set to the manager's informer cache the live state is the one observed through
+// watches, so an object edited outside the operator is diffed and written again.
+//
+// Reconcile lists every possible GVK the first time it runs for an owner and cluster, and after
+// that only looks at the objects that were in the cache last time but are no longer, fetching each
+// of them. Objects that become owned by other means in between are not noticed until the next full
+// listing, which happens every resync interval, or after Forget.
+//
+// Entries not used for the resync interval, or for a day when resync is zero, are dropped, so
+// owners deleted without Teardown are not remembered forever. Dropping an entry only costs a diff,
+// or a full listing, the next time the object or owner is seen.
+type ChangeTracker struct {
+	mu      sync.RWMutex
+	resync  time.Duration
+	expiry  time.Duration
+	swept   time.Time
+	applied map[ObjectRef]appliedState
+	owners  map[types.UID]map[string]*trackedSet
+}
+
+// defaultTrackerExpiry is how long unused entries are kept when the resync interval is zero.
+const defaultTrackerExpiry = 24 * time.Hour
+
+// appliedState is what ApplyAll last wrote, or found already in place, for an object.
+type appliedState struct {
+	hash            string
+	resourceVersion string
+	seen            time.Time
+}
+
+// trackedSet holds the objects an owner had in the cache of a cluster at its last Reconcile.
+type trackedSet struct {
+	refs   map[ObjectRef]bool
+	listed time.Time
+	seen   time.Time
+}
+
+// NewChangeTracker returns a ChangeTracker whose Reconcile falls back to listing every possible GVK
+// once resync has passed since the last full listing for an owner. With a resync of zero there is no
+// periodic listing; an owner is listed again after Forget, or once its entry expires after a day
+// without use.
+func NewChangeTracker(resync time.Duration) *ChangeTracker {
+	expiry := resync
+	if expiry <= 0 {
+		expiry = defaultTrackerExpiry
+	}
+	return &ChangeTracker{
+		resync:  resync,
+		expiry:  expiry,
+		swept:   time.Now(),
+		applied: make(map[ObjectRef]appliedState),
+		owners:  make(map[types.UID]map[string]*trackedSet),
+	}
+}
+
+// Forget drops everything remembered about an owner's objects, so the next Reconcile for it lists
+// every possible GVK again. Teardown calls it once the owner's finalizer has been removed.
+func (t *ChangeTracker) Forget(owner types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, set := range t.owners[owner] {
+		for ref := range set.refs {
+			delete(t.applied, ref)
+		}
+	}
+	delete(t.owners, owner)
+}
+
+// unchanged reports whether an object's desired content and live resourceVersion are the ones
+// recorded when it was last applied. An expired entry is dropped and reports a change.
+func (t *ChangeTracker) unchanged(ref ObjectRef, hash string, resourceVersion string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.applied[ref]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(state.seen) >= t.expiry {
+		delete(t.applied, ref)
+		return false
+	}
+	if resourceVersion == "" || state.hash != hash || state.resourceVersion != resourceVersion {
+		return false
+	}
+	state.seen = now
+	t.applied[ref] = state
+	return true
+}
+
+func (t *ChangeTracker) recordApplied(ref ObjectRef, hash string, resourceVersion string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.applied[ref] = appliedState{hash: hash, resourceVersion: resourceVersion, seen: time.Now()}
+	t.sweep()
+}
+
+func (t *ChangeTracker) recordDeleted(ref ObjectRef) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.applied, ref)
+}
+
+// tracked returns the objects an owner had in the cache of a cluster at its last Reconcile, or
+// false when Reconcile must list every possible GVK instead. An expired entry is dropped.
+func (t *ChangeTracker) tracked(owner types.UID, cluster string) (map[ObjectRef]bool, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	set, ok := t.owners[owner][cluster]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(set.seen) >= t.expiry {
+		t.dropTracked(owner, cluster)
+		return nil, false
+	}
+	if t.resync > 0 && time.Since(set.listed) >= t.resync {
+		return nil, false
+	}
+	return set.refs, true
+}
+
+// gvks returns the GVKs of the objects tracked for an owner in any cluster.
+func (t *ChangeTracker) gvks(owner types.UID) map[schema.GroupVersionKind]bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	gvks := make(map[schema.GroupVersionKind]bool)
+	for _, set := range t.owners[owner] {
+		for ref := range set.refs {
+			gvks[ref.GVK] = true
+		}
+	}
+	return gvks
+}
+
+// recordTracked replaces the objects tracked for an owner in a cluster. The time of the last full
+// listing is only moved on when listed is set.
+func (t *ChangeTracker) recordTracked(owner types.UID, cluster string, refs map[ObjectRef]bool, listed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clusters, ok := t.owners[owner]
+	if !ok {
+		clusters = make(map[string]*trackedSet)
+		t.owners[owner] = clusters
+	}
+	set, ok := clusters[cluster]
+	if !ok {
+		set = &trackedSet{}
+		clusters[cluster] = set
+	}
+	set.refs = refs
+	set.seen = time.Now()
+	if listed {
+		set.listed = set.seen
+	}
+	t.sweep()
+}
+
+// sweep drops every entry not used within the expiry, at most once per expiry. It must be called
+// with the lock held.
+func (t *ChangeTracker) sweep() {
+	now := time.Now()
+	if now.Sub(t.swept) < t.expiry {
+		return
+	}
+	t.swept = now
+
+	for ref, state := range t.applied {
+		if now.Sub(state.seen) >= t.expiry {
+			delete(t.applied, ref)
+		}
+	}
+	for owner, clusters := range t.owners {
+		for cluster, set := range clusters {
+			if now.Sub(set.seen) >= t.expiry {
+				t.dropTracked(owner, cluster)
+			}
+		}
+	}
+}
+
+// dropTracked forgets the objects tracked for an owner in a cluster. It must be called with the
+// lock held.
+func (t *ChangeTracker) dropTracked(owner types.UID, cluster string) {
+	delete(t.owners[owner], cluster)
+	if len(t.owners[owner]) == 0 {
+		delete(t.owners, owner)
+	}
+}
+
+// desiredHash returns a hash of the content ApplyAll writes for a resource, ignoring server
+// populated metadata, and the status unless it is written too.
+func (o *ObjectCache) desiredHash(res *k8sResource) (string, error) {
+	content, err := o.exportContent(res.Object, ExportOptions{StripServerFields: true})
+	if err != nil {
+		return "", err
+	}
+	if !res.Status {
+		delete(content, "status")
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cachedRefs returns a reference to every object in the cache for a cluster.
+func (o *ObjectCache) cachedRefs(cluster string) map[ObjectRef]bool {
+	refs := make(map[ObjectRef]bool)
+	for key, nns := range o.resourceTracker {
+		if key.Cluster != cluster {
+			continue
+		}
+		for nn := range nns {
+			refs[ObjectRef{Cluster: cluster, GVK: key.GVK, NamespacedName: nn}] = true
+		}
+	}
+	return refs
+}
+
+// trackedOrphans returns the objects owned by ownedUID that were in the cache at the last Reconcile
+// but are no longer, fetching each of them rather than listing. Those left out by the list options
+// are added to kept, so they are still tracked. It returns false when there is nothing tracked yet,
+// or the list options cannot be checked against a single object, and the caller must list instead.
+func (o *ObjectCache) trackedOrphans(ctx context.Context, kclient client.Client, cluster string, ownedUID types.UID, kept map[ObjectRef]bool, opts ...client.ListOption) ([]unstructured.Unstructured, bool, error) {
+	tracker := o.config.options.ChangeTracker
+	if tracker == nil {
+		return nil, false, nil
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if listOpts.FieldSelector != nil && !listOpts.FieldSelector.Empty() {
+		return nil, false, nil
+	}
+	tracked, ok := tracker.tracked(ownedUID, cluster)
+	if !ok {
+		return nil, false, nil
+	}
+	cached := o.cachedRefs(cluster)
+
+	var orphans []unstructured.Unstructured
+	for _, ref := range sortedRefs(tracked) {
+		if cached[ref] {
+			continue
+		}
+		if _, ok := o.config.possibleGVKs[ref.GVK]; !ok {
+			continue
+		}
+		if _, ok := o.config.protectedGVKs[ref.GVK]; ok {
+			continue
+		}
+		if listOpts.Namespace != "" && ref.NamespacedName.Namespace != listOpts.Namespace {
+			kept[ref] = true
+			continue
+		}
+
+		obj := newUnstructured(ref.GVK)
+		if err := kclient.Get(ctx, ref.NamespacedName, obj); err != nil {
+			if k8serr.IsNotFound(err) {
+				continue
+			}
+			return nil, true, fmt.Errorf("[%s]: %w", ref, err)
+		}
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(obj.GetLabels())) {
+			kept[ref] = true
+			continue
+		}
+		if o.owned(obj, ownedUID) {
+			orphans = append(orphans, *obj)
+		}
+	}
+	return orphans, true, nil
+}
+
+// sortedRefs returns the references in a set ordered by their string form, so deletes happen in a
+// reproducible order.
+func sortedRefs(set map[ObjectRef]bool) []ObjectRef {
+	refs := make([]ObjectRef, 0, len(set))
+	for ref := range set {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].String() < refs[j].String()
+	})
+	return refs
+}
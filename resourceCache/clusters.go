@@ -0,0 +1,127 @@
+package resourcecache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LocalCluster is the name of the cluster reached through the client passed to NewObjectCache.
+const LocalCluster = ""
+
+// trackerKey identifies the objects of one GVK written to one cluster.
+type trackerKey struct {
+	Cluster string
+	GVK     schema.GroupVersionKind
+}
+
+// ClusterResult records the outcome of ApplyAll or Reconcile in a single cluster.
+type ClusterResult struct {
+	// Applied lists the objects that were created or updated, in the order they were written.
+	Applied []ObjectRef
+	// Skipped lists the objects that were left alone because they had not changed.
+	Skipped []ObjectRef
+	// Deleted lists the objects removed by Reconcile.
+	Deleted []ObjectRef
+	// Failed lists the object whose write or delete returned Err.
+	Failed []ObjectRef
+	// Adopted lists the existing objects ApplyAll took over from no owner, as allowed by
+	// Options.AdoptionPolicy.
+	Adopted []ObjectRef
+	// Paused lists the objects left alone because of the pause annotation.
+	Paused []ObjectRef
+	// Unapplied lists the objects ApplyAll did not get to, because of an error or because its
+	// context was done.
+	Unapplied []ObjectRef
+	// Err is the error that stopped work in this cluster, if any.
+	Err error
+	// Rollback reports how the writes were undone after a failure, when Options.Transactional is
+	// set.
+	Rollback *RollbackResult
+	// Diffs holds the JSON patch of each object written by ApplyAll, when Options.History is set.
+	Diffs []ObjectDiff
+}
+
+// ApplyResult holds a ClusterResult for each cluster ApplyAll or Reconcile worked on, keyed by
+// cluster name. The local cluster is keyed by LocalCluster.
+type ApplyResult struct {
+	Clusters map[string]*ClusterResult
+}
+
+func newApplyResult() ApplyResult {
+	return ApplyResult{Clusters: make(map[string]*ClusterResult)}
+}
+
+func (r ApplyResult) cluster(name string) *ClusterResult {
+	cr, ok := r.Clusters[name]
+	if !ok {
+		cr = &ClusterResult{}
+		r.Clusters[name] = cr
+	}
+	return cr
+}
+
+// LastApplyResult returns the per cluster outcome of the most recent ApplyAll.
+func (o *ObjectCache) LastApplyResult() ApplyResult {
+	return o.lastApply
+}
+
+// LastReconcileResult returns the per cluster outcome of the most recent Reconcile.
+func (o *ObjectCache) LastReconcileResult() ApplyResult {
+	return o.lastReconcile
+}
+
+// clientFor returns the client used to reach the named cluster.
+func (o *ObjectCache) clientFor(cluster string) (client.Client, error) {
+	if cluster == LocalCluster {
+		return o.client, nil
+	}
+	kclient, ok := o.config.options.Clusters[cluster]
+	if !ok {
+		return nil, fmt.Errorf("cluster [%s] is not registered in the cache options", cluster)
+	}
+	return kclient, nil
+}
+
+// clusterNames returns the local cluster followed by every registered cluster, sorted by name.
+func (o *ObjectCache) clusterNames() []string {
+	names := []string{LocalCluster}
+	for name := range o.config.options.Clusters {
+		if name != LocalCluster {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// refFor returns the ObjectRef of a cached object.
+func (o *ObjectCache) refFor(ident ResourceIdent, nn types.NamespacedName, obj client.Object) (ObjectRef, error) {
+	gvk, err := o.gvkFor(obj)
+	if err != nil {
+		return ObjectRef{}, err
+	}
+	return ObjectRef{Cluster: identCluster(ident), GVK: gvk, NamespacedName: nn}, nil
+}
+
+// clusterError labels an error with the cluster it came from. Errors from the local cluster are
+// returned unchanged.
+func clusterError(cluster string, err error) error {
+	if cluster == LocalCluster {
+		return err
+	}
+	return fmt.Errorf("cluster [%s]: %w", cluster, err)
+}
+
+// joinErrors combines the errors from several clusters, returning a lone error as is.
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
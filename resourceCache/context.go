@@ -0,0 +1,33 @@
+package resourcecache
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bounds the time spent in each cache operation. A zero value leaves the operation bound
+// only by its context.
+type Timeouts struct {
+	// Create bounds the read made by each Create.
+	Create time.Duration
+	// Update bounds the immediate write made by Update for WriteNow idents.
+	Update time.Duration
+	// Prefetch bounds a whole Prefetch.
+	Prefetch time.Duration
+	// ApplyAll bounds a whole ApplyAll.
+	ApplyAll time.Duration
+	// Reconcile bounds a whole Reconcile or Plan.
+	Reconcile time.Duration
+	// Readiness bounds each wait on the readiness gates of a tier. Unlike the other timeouts, zero
+	// does not wait at all: the gated objects are checked once and ApplyAll returns a
+	// NotReadyError if any is not ready.
+	Readiness time.Duration
+}
+
+// withTimeout derives a context bounded by the timeout, if one is set.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
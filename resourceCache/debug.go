@@ -0,0 +1,85 @@
+package resourcecache
+
+import (
+	"encoding/json"
+)
+
+// DebugEntry describes a cached object in the structured dumps made by DebugLog and DebugJSON.
+type DebugEntry struct {
+	Provider  string `json:"provider"`
+	Purpose   string `json:"purpose"`
+	Cluster   string `json:"cluster,omitempty"`
+	GVK       string `json:"gvk"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Update is set when the object already exists, Dirty when ApplyAll would write it and Status
+	// when its status is written too.
+	Update bool `json:"update"`
+	Dirty  bool `json:"dirty"`
+	Status bool `json:"status"`
+	// Object is the cached object with server populated metadata removed and Secret values
+	// redacted.
+	Object map[string]interface{} `json:"object,omitempty"`
+	// Error records why the object could not be described in full.
+	Error string `json:"error,omitempty"`
+}
+
+// DebugEntries describes every cached object, in apply order. Problems with a single object, such
+// as a type missing from the scheme, are recorded in its entry rather than stopping the dump.
+func (o *ObjectCache) DebugEntries() []DebugEntry {
+	var entries []DebugEntry
+
+	for _, v := range o.sortedObjects().objs {
+		entry := DebugEntry{
+			Provider:  v.Ident.GetProvider(),
+			Purpose:   v.Ident.GetPurpose(),
+			Cluster:   identCluster(v.Ident),
+			Namespace: v.NamespacedName.Namespace,
+			Name:      v.NamespacedName.Name,
+			Update:    bool(v.Resource.Update),
+			Status:    v.Resource.Status,
+		}
+
+		dirty, err := isDirty(v.Resource)
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		entry.Dirty = dirty
+
+		gvk, err := o.gvkFor(v.Resource.Object)
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.GVK = gvk.String()
+			content, err := o.exportContent(v.Resource.Object, ExportOptions{StripServerFields: true, RedactSecrets: true})
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			entry.Object = content
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// DebugLog writes a structured description of every cached object to the cache's logger at the
+// given verbosity. Secret values are redacted.
+func (o *ObjectCache) DebugLog(level int) {
+	entries := o.DebugEntries()
+	log := o.log.V(level)
+	log.Info("Cache contents", "objects", len(entries))
+	for _, entry := range entries {
+		log.Info("Cached object", "provider", entry.Provider, "purpose", entry.Purpose, "cluster", entry.Cluster, "gvk", entry.GVK, "namespace", entry.Namespace, "name", entry.Name, "update", entry.Update, "dirty", entry.Dirty, "status", entry.Status, "object", entry.Object, "error", entry.Error)
+	}
+}
+
+// DebugJSON returns a JSON document describing every cached object, suitable for serving from a
+// debug endpoint. Secret values are redacted.
+func (o *ObjectCache) DebugJSON() ([]byte, error) {
+	entries := o.DebugEntries()
+	if entries == nil {
+		entries = []DebugEntry{}
+	}
+	return json.MarshalIndent(map[string]interface{}{"objects": entries}, "", "  ")
+}
@@ -0,0 +1,194 @@
+package resourcecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldDrift is a field whose live value differs from the desired value in the cache. A field
+// missing on one side has a nil value there. Secret values are redacted.
+type FieldDrift struct {
+	Path    string      `json:"path"`
+	Desired interface{} `json:"desired"`
+	Live    interface{} `json:"live"`
+}
+
+// ObjectDrift lists the fields of an object that have drifted from the cache, or reports that the
+// object no longer exists.
+type ObjectDrift struct {
+	Ref     ObjectRef
+	Missing bool
+	Fields  []FieldDrift
+}
+
+// DetectDrift compares the desired state the providers have built in the cache with a fresh read of
+// every cached object that already exists, without writing anything, and returns the objects that
+// differ: the changes ApplyAll would overwrite, such as a kubectl edit made since the last apply.
+// Run it after the providers have updated the cache and before ApplyAll. Objects are compared the
+// way ApplyAll decides what to skip: the live object is read into the cached object's type, the
+// status is ignored unless the object is marked for a status update, and metadata populated by the
+// API server, such as resourceVersion and managedFields, is ignored too.
+func (o *ObjectCache) DetectDrift() ([]ObjectDrift, error) {
+	return o.DetectDriftContext(o.ctx)
+}
+
+// DetectDriftContext is DetectDrift using the given context, bounded by Timeouts.Reconcile if set.
+func (o *ObjectCache) DetectDriftContext(ctx context.Context) ([]ObjectDrift, error) {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Reconcile)
+	defer cancel()
+
+	var drifts []ObjectDrift
+
+	for _, v := range o.sortedObjects().objs {
+		if v.Ident.GetWriteNow() || !bool(v.Resource.Update) {
+			continue
+		}
+
+		ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object)
+		if err != nil {
+			return drifts, err
+		}
+		kclient, err := o.clientFor(ref.Cluster)
+		if err != nil {
+			return drifts, err
+		}
+
+		live := newObjectLike(v.Resource.Object, ref.GVK)
+		if err := kclient.Get(ctx, v.NamespacedName, live); err != nil {
+			if k8serr.IsNotFound(err) {
+				drifts = append(drifts, ObjectDrift{Ref: ref, Missing: true})
+				continue
+			}
+			return drifts, fmt.Errorf("[%s]: %w", ref, err)
+		}
+
+		desiredContent, err := driftContent(v.Resource.Object, v.Resource.Status)
+		if err != nil {
+			return drifts, err
+		}
+		liveContent, err := driftContent(live, v.Resource.Status)
+		if err != nil {
+			return drifts, err
+		}
+		if equality.Semantic.DeepEqual(desiredContent, liveContent) {
+			continue
+		}
+
+		drift := ObjectDrift{Ref: ref}
+		if drift.Fields, err = driftFields(desiredContent, liveContent); err != nil {
+			return drifts, fmt.Errorf("[%s]: %w", ref, err)
+		}
+		if ref.GVK.GroupKind() == (schema.GroupKind{Kind: "Secret"}) {
+			redactDrift(drift.Fields)
+		}
+		o.log.Info("Drift detected", "namespace", ref.NamespacedName.Namespace, "name", ref.NamespacedName.Name, "kind", ref.GVK.Kind, "cluster", ref.Cluster, "fields", len(drift.Fields))
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// newObjectLike returns an empty object of the same type as obj, to read the live copy into.
+func newObjectLike(obj client.Object, gvk schema.GroupVersionKind) client.Object {
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		return newUnstructured(gvk)
+	}
+	return reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+}
+
+// driftContent returns the content of an object that is compared for drift.
+func driftContent(obj client.Object, withStatus bool) (map[string]interface{}, error) {
+	content, err := objectContent(obj, !withStatus)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range serverFields {
+		unstructured.RemoveNestedField(content, "metadata", field)
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	return content, nil
+}
+
+// jsonPointerUnescaper turns a JSON pointer segment back into a map key.
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// driftFields lists the fields that differ between the desired and live content, from the JSON
+// patch that would turn the live content into the desired one.
+func driftFields(desired, live map[string]interface{}) ([]FieldDrift, error) {
+	desiredJSON, err := json.Marshal(desired)
+	if err != nil {
+		return nil, err
+	}
+	liveJSON, err := json.Marshal(live)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.CreatePatch(liveJSON, desiredJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]FieldDrift, 0, len(patch))
+	for _, op := range patch {
+		path := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+		for i, part := range path {
+			path[i] = jsonPointerUnescaper.Replace(part)
+		}
+		field := FieldDrift{Path: strings.Join(path, "."), Live: valueAt(live, path)}
+		if op.Operation != "remove" {
+			field.Desired = op.Value
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Path < fields[j].Path
+	})
+	return fields, nil
+}
+
+// valueAt returns the value at a path of map keys and list indexes, or nil if there is none.
+func valueAt(content interface{}, path []string) interface{} {
+	for _, part := range path {
+		switch v := content.(type) {
+		case map[string]interface{}:
+			content = v[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			content = v[i]
+		default:
+			return nil
+		}
+	}
+	return content
+}
+
+// redactDrift hides the drifted values held in a Secret's data.
+func redactDrift(fields []FieldDrift) {
+	for i := range fields {
+		if !strings.HasPrefix(fields[i].Path, "data") && !strings.HasPrefix(fields[i].Path, "stringData") {
+			continue
+		}
+		if fields[i].Desired != nil {
+			fields[i].Desired = redactedValue
+		}
+		if fields[i].Live != nil {
+			fields[i].Live = redactedValue
+		}
+	}
+}
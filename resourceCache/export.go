@@ -0,0 +1,145 @@
+package resourcecache
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportFormat selects the serialisation used by Export.
+type ExportFormat string
+
+const (
+	// ExportYAML writes one YAML document per object, separated by `---`.
+	ExportYAML ExportFormat = "yaml"
+	// ExportJSON writes a single JSON `List` holding every object.
+	ExportJSON ExportFormat = "json"
+)
+
+// ExportOptions controls what is written by Export.
+type ExportOptions struct {
+	// StripServerFields removes metadata populated by the API server, such as resourceVersion, uid,
+	// managedFields and creationTimestamp, and the status of exported objects.
+	StripServerFields bool
+	// RedactSecrets replaces the values held in Secrets with a placeholder, base64 encoded in data
+	// so the exported Secret can still be decoded and applied.
+	RedactSecrets bool
+}
+
+// redactedValue replaces every value of a redacted Secret.
+const redactedValue = "REDACTED"
+
+// redactedData replaces every value in the data of a redacted Secret, which must be base64.
+var redactedData = base64.StdEncoding.EncodeToString([]byte(redactedValue))
+
+// serverFields are the metadata fields removed when ExportOptions.StripServerFields is set.
+var serverFields = []string{
+	"resourceVersion",
+	"uid",
+	"managedFields",
+	"creationTimestamp",
+	"generation",
+	"selfLink",
+}
+
+// Export writes every cached object, in apply order, to the writer in the given format. Objects that
+// were never given a name, and so would not be applied, are left out.
+func (o *ObjectCache) Export(writer io.Writer, format ExportFormat, opts ...ExportOptions) error {
+	var options ExportOptions
+	if len(opts) >= 1 {
+		options = opts[0]
+	}
+
+	var items []interface{}
+	for _, v := range o.sortedObjects().objs {
+		if v.Resource.Object.GetName() == "" {
+			continue
+		}
+		content, err := o.exportContent(v.Resource.Object, options)
+		if err != nil {
+			return fmt.Errorf("exporting [%s]: %w", v.NamespacedName, err)
+		}
+		if options.StripServerFields {
+			delete(content, "status")
+		}
+		items = append(items, content)
+	}
+
+	switch format {
+	case ExportYAML:
+		for _, item := range items {
+			data, err := yaml.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(writer, "---\n%s", data); err != nil {
+				return err
+			}
+		}
+	case ExportJSON:
+		if items == nil {
+			items = []interface{}{}
+		}
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      items,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(writer, "%s\n", data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown export format [%s]", format)
+	}
+	return nil
+}
+
+// exportContent converts a cached object to its unstructured form with apiVersion and kind set and
+// the export options applied.
+func (o *ObjectCache) exportContent(obj client.Object, options ExportOptions) (map[string]interface{}, error) {
+	gvk, err := o.gvkFor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := toContent(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+
+	if options.StripServerFields {
+		for _, field := range serverFields {
+			unstructured.RemoveNestedField(content, "metadata", field)
+		}
+	}
+
+	if options.RedactSecrets && gvk.GroupKind() == (schema.GroupKind{Kind: "Secret"}) {
+		redactValues(content, "data", redactedData)
+		redactValues(content, "stringData", redactedValue)
+	}
+
+	return content, nil
+}
+
+func redactValues(content map[string]interface{}, field string, placeholder string) {
+	values, ok := content[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k := range values {
+		values[k] = placeholder
+	}
+}
@@ -0,0 +1,89 @@
+package resourcecache
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Filter selects the cached objects returned by Find. Fields left empty match every object.
+type Filter struct {
+	Provider  string
+	Purpose   string
+	GVK       schema.GroupVersionKind
+	Namespace string
+	// LabelSelector matches the labels of the cached copy of each object.
+	LabelSelector labels.Selector
+}
+
+// Entry describes a cached object returned by Find.
+type Entry struct {
+	Ident          ResourceIdent
+	NamespacedName types.NamespacedName
+	GVK            schema.GroupVersionKind
+	// Update is set when the object already exists and ApplyAll will update rather than create it.
+	Update bool
+	// Dirty is set when ApplyAll would write the object.
+	Dirty bool
+	// Status is set when the object's status is written by ApplyAll.
+	Status bool
+	// Object is a copy of the cached object; changes to it must be written back with Update.
+	Object client.Object
+}
+
+// Find returns an entry for every cached object matching the filter, in apply order. It lets
+// providers that run late act on objects created by others, for instance every Service in the
+// cache, without knowing their idents.
+func (o *ObjectCache) Find(filter Filter) ([]Entry, error) {
+	var entries []Entry
+
+	for _, v := range o.sortedObjects().objs {
+		if filter.Provider != "" && v.Ident.GetProvider() != filter.Provider {
+			continue
+		}
+		if filter.Purpose != "" && v.Ident.GetPurpose() != filter.Purpose {
+			continue
+		}
+		if filter.Namespace != "" && v.NamespacedName.Namespace != filter.Namespace {
+			continue
+		}
+		if filter.LabelSelector != nil && !filter.LabelSelector.Matches(labels.Set(v.Resource.Object.GetLabels())) {
+			continue
+		}
+
+		gvk, err := o.gvkFor(v.Ident.GetType())
+		if err != nil {
+			return nil, err
+		}
+		if !filter.GVK.Empty() && gvk != filter.GVK {
+			continue
+		}
+
+		dirty, err := isDirty(v.Resource)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, Entry{
+			Ident:          v.Ident,
+			NamespacedName: v.NamespacedName,
+			GVK:            gvk,
+			Update:         bool(v.Resource.Update),
+			Dirty:          dirty,
+			Status:         v.Resource.Status,
+			Object:         v.Resource.Object.DeepCopyObject().(client.Object),
+		})
+	}
+	return entries, nil
+}
+
+// isDirty reports whether ApplyAll would write a resource.
+func isDirty(res *k8sResource) (bool, error) {
+	apply, err := needsApply(res)
+	if err != nil || apply {
+		return apply, err
+	}
+	return statusChanged(res)
+}
@@ -0,0 +1,219 @@
+package resourcecache
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultHistorySize is the number of summaries kept per owner when NewHistory is given no size.
+const defaultHistorySize = 10
+
+// History keeps summaries of the most recent ApplyAll and Reconcile calls for each owner, and serves
+// them as JSON so they can be mounted on the manager's metrics or health server. An ObjectCache only
+// lives for a single reconcile, so one History is created when the operator starts and passed to
+// every cache in Options.History. It is safe for concurrent use. The summaries of an owner are kept
+// until Forget is called for it, which Teardown does once the owner's finalizer has been removed.
+type History struct {
+	mu        sync.RWMutex
+	size      int
+	summaries map[types.UID][]Summary
+}
+
+// Summary describes a single ApplyAll or Reconcile.
+type Summary struct {
+	// Operation is either "apply" or "reconcile".
+	Operation string    `json:"operation"`
+	Owner     types.UID `json:"owner"`
+	Start     time.Time `json:"start"`
+	Duration  string    `json:"duration"`
+	// Clusters holds the outcome in each cluster, keyed by cluster name.
+	Clusters map[string]ClusterSummary `json:"clusters"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+// ClusterSummary is the JSON form of a ClusterResult.
+type ClusterSummary struct {
+	Applied   []string     `json:"applied,omitempty"`
+	Skipped   []string     `json:"skipped,omitempty"`
+	Deleted   []string     `json:"deleted,omitempty"`
+	Failed    []string     `json:"failed,omitempty"`
+	Adopted   []string     `json:"adopted,omitempty"`
+	Paused    []string     `json:"paused,omitempty"`
+	Unapplied []string     `json:"unapplied,omitempty"`
+	Diffs     []ObjectDiff `json:"diffs,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// ObjectDiff is the JSON patch ApplyAll applied to an object, computed with server populated
+// metadata removed and Secret values redacted.
+type ObjectDiff struct {
+	Object string                `json:"object"`
+	Patch  []jsonpatch.Operation `json:"patch"`
+}
+
+// NewHistory returns a History keeping the last size summaries for each owner, or 10 if size is
+// not positive.
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &History{size: size, summaries: make(map[types.UID][]Summary)}
+}
+
+// Add records a summary, dropping the oldest one for its owner when the history is full. Summaries
+// with no owner are not recorded, so unrelated caches are not mixed up.
+func (h *History) Add(summary Summary) {
+	if summary.Owner == "" {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	summaries := append(h.summaries[summary.Owner], summary)
+	if len(summaries) > h.size {
+		summaries = summaries[len(summaries)-h.size:]
+	}
+	h.summaries[summary.Owner] = summaries
+}
+
+// Forget drops the summaries kept for an owner.
+func (h *History) Forget(owner types.UID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.summaries, owner)
+}
+
+// Summaries returns the summaries kept for an owner, oldest first.
+func (h *History) Summaries(owner types.UID) []Summary {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]Summary(nil), h.summaries[owner]...)
+}
+
+// ServeHTTP writes the summaries as JSON. The owner query parameter selects the summaries of a
+// single owner; without it every owner's summaries are written, keyed by UID.
+func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body interface{}
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		summaries := h.Summaries(types.UID(owner))
+		if summaries == nil {
+			summaries = []Summary{}
+		}
+		body = summaries
+	} else {
+		h.mu.RLock()
+		all := make(map[types.UID][]Summary, len(h.summaries))
+		for owner, summaries := range h.summaries {
+			all[owner] = append([]Summary(nil), summaries...)
+		}
+		h.mu.RUnlock()
+		body = all
+	}
+
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// record adds a summary of an ApplyAll or Reconcile to the configured history. Nothing is recorded
+// without an owner, as when ApplyAll runs with no Options.Owner.
+func (o *ObjectCache) record(operation string, owner types.UID, start time.Time, result ApplyResult, err error) {
+	history := o.config.options.History
+	if history == nil {
+		return
+	}
+	if owner == "" {
+		o.log.V(1).Info("Not recording history without an owner", "operation", operation)
+		return
+	}
+
+	summary := Summary{
+		Operation: operation,
+		Owner:     owner,
+		Start:     start,
+		Duration:  time.Since(start).String(),
+		Clusters:  make(map[string]ClusterSummary, len(result.Clusters)),
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	for name, cr := range result.Clusters {
+		cs := ClusterSummary{
+			Applied:   refStrings(cr.Applied),
+			Skipped:   refStrings(cr.Skipped),
+			Deleted:   refStrings(cr.Deleted),
+			Failed:    refStrings(cr.Failed),
+			Adopted:   refStrings(cr.Adopted),
+			Paused:    refStrings(cr.Paused),
+			Unapplied: refStrings(cr.Unapplied),
+			Diffs:     cr.Diffs,
+		}
+		if cr.Err != nil {
+			cs.Error = cr.Err.Error()
+		}
+		summary.Clusters[name] = cs
+	}
+	history.Add(summary)
+}
+
+// historyOwner returns the UID ApplyAll summaries are recorded under.
+func (o *ObjectCache) historyOwner() types.UID {
+	if o.config.options.Owner == nil {
+		return ""
+	}
+	return o.config.options.Owner.GetUID()
+}
+
+// diff returns the JSON patch that writing a resource applies to the object fetched at Create.
+func (o *ObjectCache) diff(ref ObjectRef, res *k8sResource) (ObjectDiff, error) {
+	options := ExportOptions{StripServerFields: true, RedactSecrets: true}
+	orig, err := o.exportContent(res.origObject, options)
+	if err != nil {
+		return ObjectDiff{}, err
+	}
+	desired, err := o.exportContent(res.Object, options)
+	if err != nil {
+		return ObjectDiff{}, err
+	}
+	if !res.Update {
+		orig = map[string]interface{}{}
+	}
+
+	origJSON, err := json.Marshal(orig)
+	if err != nil {
+		return ObjectDiff{}, err
+	}
+	desiredJSON, err := json.Marshal(desired)
+	if err != nil {
+		return ObjectDiff{}, err
+	}
+	patch, err := jsonpatch.CreatePatch(origJSON, desiredJSON)
+	if err != nil {
+		return ObjectDiff{}, err
+	}
+	return ObjectDiff{Object: ref.String(), Patch: patch}, nil
+}
+
+func refStrings(refs []ObjectRef) []string {
+	var s []string
+	for _, ref := range refs {
+		s = append(s, ref.String())
+	}
+	return s
+}
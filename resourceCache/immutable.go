@@ -0,0 +1,301 @@
+package resourcecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	batch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ImmutableAction decides what the cache does when an update would change a field that k8s will not
+// allow to be changed on an existing object.
+type ImmutableAction string
+
+const (
+	// ImmutableFail aborts the apply with an ImmutableFieldError naming the offending fields. This
+	// is the default.
+	ImmutableFail ImmutableAction = ""
+	// ImmutableRecreate deletes the live object and creates it again from the cached copy.
+	ImmutableRecreate ImmutableAction = "Recreate"
+)
+
+// ImmutablePolicy is set on a ResourceIdent and controls how immutable field violations are
+// handled for every object stored under that ident. Propagation is only used by
+// ImmutableRecreate and defaults to background deletion when empty.
+type ImmutablePolicy struct {
+	Action      ImmutableAction
+	Propagation metav1.DeletionPropagation
+}
+
+// ImmutableFieldRule lists dotted field paths of a kind that cannot be changed once the object
+// exists. If Condition is set the rule is only enforced when it returns true for the live object.
+type ImmutableFieldRule struct {
+	Paths     []string
+	Condition func(live map[string]interface{}) bool
+}
+
+// recreateTimeout bounds how long a recreate waits for the old object to disappear.
+const recreateTimeout = 30 * time.Second
+
+func markedImmutable(live map[string]interface{}) bool {
+	immutable, _, _ := unstructured.NestedBool(live, "immutable")
+	return immutable
+}
+
+// DefaultImmutableFields is used when Options.ImmutableFields is not set.
+var DefaultImmutableFields = map[schema.GroupVersionKind][]ImmutableFieldRule{
+	{Group: "batch", Version: "v1", Kind: "Job"}: {
+		{Paths: []string{"spec.selector", "spec.template", "spec.completionMode"}},
+	},
+	{Group: "apps", Version: "v1", Kind: "Deployment"}: {
+		{Paths: []string{"spec.selector"}},
+	},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"}: {
+		{Paths: []string{"spec.selector"}},
+	},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"}: {
+		{Paths: []string{"spec.selector", "spec.serviceName", "spec.volumeClaimTemplates", "spec.podManagementPolicy"}},
+	},
+	{Group: "", Version: "v1", Kind: "Secret"}: {
+		{Paths: []string{"type"}},
+		{Paths: []string{"data", "immutable"}, Condition: markedImmutable},
+	},
+	{Group: "", Version: "v1", Kind: "ConfigMap"}: {
+		{Paths: []string{"data", "binaryData", "immutable"}, Condition: markedImmutable},
+	},
+}
+
+// ImmutableFieldError is returned when an update would change immutable fields and the ident's
+// policy does not allow the object to be recreated.
+type ImmutableFieldError struct {
+	Kind           string
+	NamespacedName types.NamespacedName
+	Fields         []string
+	Err            error
+}
+
+func (e *ImmutableFieldError) Error() string {
+	return fmt.Sprintf("cannot update %s [%s]: immutable field(s) changed: %s", e.Kind, e.NamespacedName, strings.Join(e.Fields, ", "))
+}
+
+func (e *ImmutableFieldError) Unwrap() error {
+	return e.Err
+}
+
+// immutableFieldsChanged compares the cached object with the live copy taken at Create and
+// returns every field covered by the rule table that the cached object sets to a different value.
+// Parts the cached object leaves unset are not compared, as the live copy holds server defaults for
+// them; clearing a field the server does not default is caught by the Invalid error on update.
+func (o *ObjectCache) immutableFieldsChanged(res *k8sResource) ([]string, error) {
+	gvk, err := o.gvkFor(res.Object)
+	if err != nil {
+		return nil, err
+	}
+	rules, ok := o.config.options.ImmutableFields[gvk]
+	if !ok {
+		return nil, nil
+	}
+
+	live, err := toContent(res.origObject)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := toContent(res.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for _, rule := range rules {
+		if rule.Condition != nil && !rule.Condition(live) {
+			continue
+		}
+		for _, path := range rule.Paths {
+			fieldPath := strings.Split(path, ".")
+			liveVal, _, _ := unstructured.NestedFieldNoCopy(live, fieldPath...)
+			desiredVal, _, _ := unstructured.NestedFieldNoCopy(desired, fieldPath...)
+			if desiredVal != nil && !setFieldsEqual(desiredVal, liveVal) {
+				fields = append(fields, path)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// setFieldsEqual reports whether every field set in the desired value has the same value in the
+// live one. Maps are compared on the desired keys only, and lists element by element.
+func setFieldsEqual(desired, live interface{}) bool {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range d {
+			if v == nil {
+				continue
+			}
+			if !setFieldsEqual(v, l[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !setFieldsEqual(d[i], l[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return equality.Semantic.DeepEqual(desired, live)
+}
+
+// immutableFieldsFromError extracts the offending fields from an Invalid error returned by the API
+// server, or nil if the error was not caused by an immutable field.
+func immutableFieldsFromError(err error) []string {
+	if !k8serr.IsInvalid(err) {
+		return nil
+	}
+	var status k8serr.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+
+	var fields []string
+	for _, cause := range status.Status().Details.Causes {
+		if strings.Contains(cause.Message, "immutable") {
+			fields = append(fields, cause.Field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// handleImmutable either fails or recreates the object, depending on the ident's policy.
+func (o *ObjectCache) handleImmutable(ctx context.Context, kclient client.Client, ident ResourceIdent, nn types.NamespacedName, res *k8sResource, fields []string, cause error) error {
+	policy := identImmutablePolicy(ident)
+	kind := res.Object.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := o.gvkFor(res.Object); err == nil {
+		kind = gvk.Kind
+	}
+
+	if policy.Action != ImmutableRecreate {
+		return &ImmutableFieldError{Kind: kind, NamespacedName: nn, Fields: fields, Err: cause}
+	}
+
+	o.log.Info("RECREATE resource ", "namespace", nn.Namespace, "name", nn.Name, "provider", ident.GetProvider(), "purpose", ident.GetPurpose(), "kind", kind, "fields", fields)
+	return o.recreate(ctx, kclient, res, policy.Propagation)
+}
+
+// recreateError is returned by recreate when the live object was deleted but could not be created
+// again, so that a transactional ApplyAll knows it has to restore it.
+type recreateError struct {
+	err error
+}
+
+func (e *recreateError) Error() string {
+	return fmt.Sprintf("object deleted but not recreated: %s", e.err)
+}
+
+func (e *recreateError) Unwrap() error {
+	return e.err
+}
+
+// recreate deletes the live object, waits for it to be gone and then creates the cached copy. Any
+// failure after the delete is returned as a recreateError.
+func (o *ObjectCache) recreate(ctx context.Context, kclient client.Client, res *k8sResource, propagation metav1.DeletionPropagation) error {
+	if propagation == "" {
+		propagation = metav1.DeletePropagationBackground
+	}
+
+	uid := res.origObject.GetUID()
+	deleteOpts := []client.DeleteOption{client.PropagationPolicy(propagation)}
+	if uid != "" {
+		deleteOpts = append(deleteOpts, client.Preconditions{UID: &uid})
+	}
+
+	if err := o.throttle(ctx, "delete"); err != nil {
+		return err
+	}
+	if err := kclient.Delete(ctx, res.Object, deleteOpts...); err != nil && !k8serr.IsNotFound(err) {
+		return err
+	}
+
+	nn := types.NamespacedName{Namespace: res.Object.GetNamespace(), Name: res.Object.GetName()}
+	probe := res.Object.DeepCopyObject().(client.Object)
+	err := wait.PollUntilContextTimeout(ctx, 250*time.Millisecond, recreateTimeout, true, func(ctx context.Context) (bool, error) {
+		err := kclient.Get(ctx, nn, probe)
+		if k8serr.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return &recreateError{err: fmt.Errorf("waiting for [%s] to be deleted before recreate: %w", nn, err)}
+	}
+
+	prepareForRecreate(res.Object)
+
+	if err := o.throttle(ctx, "create"); err != nil {
+		return &recreateError{err: err}
+	}
+	if err := kclient.Create(ctx, res.Object); err != nil {
+		return &recreateError{err: err}
+	}
+	res.Update = true
+	return nil
+}
+
+// prepareForRecreate clears the server populated fields that would stop the object being created
+// again.
+func prepareForRecreate(obj client.Object) {
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetDeletionTimestamp(nil)
+	obj.SetManagedFields(nil)
+	obj.SetGeneration(0)
+
+	// Jobs get a generated selector bound to the old object's UID, which must not be carried over.
+	switch job := obj.(type) {
+	case *batch.Job:
+		if job.Spec.ManualSelector == nil || !*job.Spec.ManualSelector {
+			job.Spec.Selector = nil
+			for _, label := range jobGeneratedLabels {
+				delete(job.Spec.Template.Labels, label)
+			}
+		}
+	case *unstructured.Unstructured:
+		if job.GroupVersionKind().GroupKind() != batch.SchemeGroupVersion.WithKind("Job").GroupKind() {
+			return
+		}
+		if manual, _, _ := unstructured.NestedBool(job.Object, "spec", "manualSelector"); manual {
+			return
+		}
+		unstructured.RemoveNestedField(job.Object, "spec", "selector")
+		for _, label := range jobGeneratedLabels {
+			unstructured.RemoveNestedField(job.Object, "spec", "template", "metadata", "labels", label)
+		}
+	}
+}
+
+// jobGeneratedLabels are the pod template labels the Job controller derives from the Job's UID.
+var jobGeneratedLabels = []string{"controller-uid", "job-name", batch.ControllerUidLabel, batch.JobNameLabel}
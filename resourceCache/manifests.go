@@ -0,0 +1,147 @@
+package resourcecache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LoadManifests decodes a stream of YAML or JSON documents, including `List` documents, and places
+// every object it contains into the cache under the given ident. Each object goes through Create,
+// so the live state is fetched from k8s first, and the manifest content is then laid over it. The
+// loaded objects are ordered, diffed and reconciled like any other cached object.
+func (o *ObjectCache) LoadManifests(resourceIdent ResourceIdent, reader io.Reader) error {
+	return o.LoadManifestsContext(o.ctx, resourceIdent, reader)
+}
+
+// LoadManifestsContext is LoadManifests using the given context for the Create and Update of each
+// object.
+func (o *ObjectCache) LoadManifestsContext(ctx context.Context, resourceIdent ResourceIdent, reader io.Reader) error {
+	manifests, err := decodeManifests(reader)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := resourceIdent.(ResourceIdentSingle); ok && len(manifests) > 1 {
+		return fmt.Errorf("cannot load %d manifests into single ident [%s]", len(manifests), resourceIdent)
+	}
+
+	for _, manifest := range manifests {
+		if err := o.loadManifest(ctx, resourceIdent, manifest); err != nil {
+			return fmt.Errorf("loading manifest %s [%s/%s]: %w", manifest.GetKind(), manifest.GetNamespace(), manifest.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (o *ObjectCache) loadManifest(ctx context.Context, resourceIdent ResourceIdent, manifest *unstructured.Unstructured) error {
+	nn := types.NamespacedName{
+		Namespace: manifest.GetNamespace(),
+		Name:      manifest.GetName(),
+	}
+
+	live, err := o.newObject(manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := o.CreateContext(ctx, resourceIdent, nn, live); err != nil {
+		return err
+	}
+
+	liveContent, err := toContent(live)
+	if err != nil {
+		return err
+	}
+
+	desired, err := o.newObject(manifest)
+	if err != nil {
+		return err
+	}
+	if err := fromContent(overlay(liveContent, manifest.Object), desired); err != nil {
+		return err
+	}
+	desired.GetObjectKind().SetGroupVersionKind(manifest.GroupVersionKind())
+
+	return o.UpdateContext(ctx, resourceIdent, desired)
+}
+
+// newObject returns an empty object of the manifest's kind from the cache's scheme, or an
+// unstructured object if the kind is not registered.
+func (o *ObjectCache) newObject(manifest *unstructured.Unstructured) (client.Object, error) {
+	obj, err := o.scheme.New(manifest.GroupVersionKind())
+	if runtime.IsNotRegisteredError(err) {
+		return newUnstructured(manifest.GroupVersionKind()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cObj, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("kind %s is not a client.Object", manifest.GroupVersionKind())
+	}
+	return cObj, nil
+}
+
+// decodeManifests splits a multi-document YAML or JSON stream into objects, flattening lists.
+func decodeManifests(reader io.Reader) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(reader, 4096)
+
+	var manifests []*unstructured.Unstructured
+	for {
+		raw := runtime.RawExtension{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		raw.Raw = bytes.TrimSpace(raw.Raw)
+		if len(raw.Raw) == 0 || bytes.Equal(raw.Raw, []byte("null")) {
+			continue
+		}
+
+		obj, err := runtime.Decode(unstructured.UnstructuredJSONScheme, raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := obj.(type) {
+		case *unstructured.UnstructuredList:
+			for i := range v.Items {
+				manifests = append(manifests, &v.Items[i])
+			}
+		case *unstructured.Unstructured:
+			manifests = append(manifests, v)
+		default:
+			return nil, fmt.Errorf("unexpected manifest type %T", obj)
+		}
+	}
+	return manifests, nil
+}
+
+// overlay lays the manifest content over the live content. Nested maps are merged, every other
+// value set in the manifest replaces the live value.
+func overlay(live, manifest map[string]interface{}) map[string]interface{} {
+	merged := runtime.DeepCopyJSON(live)
+	for k, v := range manifest {
+		manifestMap, isMap := v.(map[string]interface{})
+		liveMap, liveIsMap := merged[k].(map[string]interface{})
+		if isMap && liveIsMap {
+			merged[k] = overlay(liveMap, manifestMap)
+			continue
+		}
+		merged[k] = runtime.DeepCopyJSONValue(v)
+	}
+	return merged
+}
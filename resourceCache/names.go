@@ -0,0 +1,129 @@
+package resourcecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultNameTemplate is the template NameFor uses for idents without a NameTemplate.
+	DefaultNameTemplate = "{{.Owner}}{{with .Suffix}}-{{.}}{{end}}-{{.Index}}"
+	// MaxGeneratedNameLength is the longest name NameFor generates, the length of a DNS label, so
+	// that the name is also usable in labels and as a Service name.
+	MaxGeneratedNameLength = 63
+	// nameHashLength is the number of hex characters of the hash appended to truncated names.
+	nameHashLength = 8
+)
+
+// NameKey is the logical key of an object of a ResourceIdentMulti, from which NameFor generates its
+// name with the ident's NameTemplate.
+type NameKey struct {
+	Owner  string
+	Index  int
+	Suffix string
+}
+
+// GenerateName executes a name template, in text/template syntax with the fields of NameKey, for a
+// key. Names longer than MaxGeneratedNameLength are truncated and given a suffix made from a hash
+// of the full name, so the same key always gives the same name and different long names stay
+// distinct.
+func GenerateName(nameTemplate string, key NameKey) (string, error) {
+	if nameTemplate == "" {
+		nameTemplate = DefaultNameTemplate
+	}
+	tmpl, err := template.New("name").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid name template [%s]: %w", nameTemplate, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, key); err != nil {
+		return "", fmt.Errorf("cannot generate name from template [%s]: %w", nameTemplate, err)
+	}
+	return truncateName(b.String()), nil
+}
+
+// truncateName shortens a name to MaxGeneratedNameLength, replacing its end with a hash of the full
+// name.
+func truncateName(name string) string {
+	if len(name) <= MaxGeneratedNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	prefix := strings.TrimRight(name[:MaxGeneratedNameLength-nameHashLength-1], "-.")
+	return prefix + "-" + hash
+}
+
+// NameFor generates the namespaced name of the object of a multi ident with the given key, using
+// the ident's NameTemplate, and records it so that other providers can find the object by key with
+// Lookup or GetByKey instead of rebuilding its name. Asking again for the same key returns the same
+// name. An error is returned if the name is not valid for the ident's kind, or is already recorded
+// for another key.
+func (o *ObjectCache) NameFor(ident ResourceIdentMulti, namespace string, key NameKey) (types.NamespacedName, error) {
+	name, err := GenerateName(ident.NameTemplate, key)
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+	nn := types.NamespacedName{Namespace: namespace, Name: name}
+
+	gvk, err := o.gvkFor(ident.GetType())
+	if err != nil {
+		return nn, err
+	}
+	if errs := nameValidator(gvk.GroupKind())(name, false); len(errs) > 0 {
+		return nn, fmt.Errorf("generated name [%s] for key %+v is invalid: %s", name, key, strings.Join(errs, ", "))
+	}
+
+	if o.names == nil {
+		o.names = make(map[ResourceIdentMulti]map[NameKey]types.NamespacedName)
+	}
+	names, ok := o.names[ident]
+	if !ok {
+		names = make(map[NameKey]types.NamespacedName)
+		o.names[ident] = names
+	}
+	if existing, ok := names[key]; ok && existing != nn {
+		return nn, fmt.Errorf("key %+v already named [%s], cannot name it [%s]", key, existing, nn)
+	}
+	for otherKey, other := range names {
+		if other == nn && otherKey != key {
+			return nn, fmt.Errorf("generated name [%s] for key %+v is already used by key %+v", nn, key, otherKey)
+		}
+	}
+	names[key] = nn
+	return nn, nil
+}
+
+// Lookup returns the namespaced name NameFor recorded for a key of a multi ident.
+func (o *ObjectCache) Lookup(ident ResourceIdentMulti, key NameKey) (types.NamespacedName, bool) {
+	nn, ok := o.names[ident][key]
+	return nn, ok
+}
+
+// GetByKey is Get for the object of a multi ident whose name NameFor generated for the key.
+func (o *ObjectCache) GetByKey(ident ResourceIdentMulti, key NameKey, object client.Object) error {
+	nn, ok := o.Lookup(ident, key)
+	if !ok {
+		return fmt.Errorf("no name recorded for key %+v of [%s/%s], cannot get", key, ident.GetProvider(), ident.GetPurpose())
+	}
+	return o.Get(ident, object, nn)
+}
+
+func copyNames(names map[ResourceIdentMulti]map[NameKey]types.NamespacedName) map[ResourceIdentMulti]map[NameKey]types.NamespacedName {
+	namesCopy := make(map[ResourceIdentMulti]map[NameKey]types.NamespacedName, len(names))
+	for ident, keys := range names {
+		keysCopy := make(map[NameKey]types.NamespacedName, len(keys))
+		for key, nn := range keys {
+			keysCopy[key] = nn
+		}
+		namesCopy[ident] = keysCopy
+	}
+	return namesCopy
+}
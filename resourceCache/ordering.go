@@ -0,0 +1,106 @@
+package resourcecache
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// orderPattern is a parsed entry of Options.Ordering. A field holding "*" matches any value.
+type orderPattern struct {
+	group   string
+	version string
+	kind    string
+}
+
+// parseOrderPattern parses an entry of Options.Ordering. An entry is either "*", matching every
+// GVK, a bare kind such as "Deployment", matching that kind in any group and version, a
+// "group/Kind" pair, or a full "group/version/Kind". Any part may be "*", and the core group is
+// written either as "core" or left empty, as in "/v1/ConfigMap".
+func parseOrderPattern(entry string) (orderPattern, error) {
+	parts := strings.Split(entry, "/")
+	var p orderPattern
+	switch len(parts) {
+	case 1:
+		p = orderPattern{group: "*", version: "*", kind: parts[0]}
+	case 2:
+		p = orderPattern{group: parts[0], version: "*", kind: parts[1]}
+	case 3:
+		p = orderPattern{group: parts[0], version: parts[1], kind: parts[2]}
+	default:
+		return p, fmt.Errorf("invalid ordering pattern [%s]: expected Kind, group/Kind or group/version/Kind", entry)
+	}
+	if p.kind == "" || p.version == "" {
+		return p, fmt.Errorf("invalid ordering pattern [%s]: kind and version cannot be empty", entry)
+	}
+	if p.group == "core" {
+		p.group = ""
+	}
+	return p, nil
+}
+
+// parseOrdering parses every entry of Options.Ordering. Invalid entries are returned as errors and
+// left out, so they match nothing.
+func parseOrdering(entries []string) ([]orderPattern, []int, error) {
+	var patterns []orderPattern
+	var indexes []int
+	var errs []error
+	for i, entry := range entries {
+		p, err := parseOrderPattern(entry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		patterns = append(patterns, p)
+		indexes = append(indexes, i)
+	}
+	return patterns, indexes, joinErrors(errs)
+}
+
+func (p orderPattern) matches(gvk schema.GroupVersionKind) bool {
+	return (p.group == "*" || p.group == gvk.Group) &&
+		(p.version == "*" || p.version == gvk.Version) &&
+		(p.kind == "*" || p.kind == gvk.Kind)
+}
+
+// specificity is the number of parts of the pattern that are not wildcards.
+func (p orderPattern) specificity() int {
+	n := 0
+	for _, part := range []string{p.group, p.version, p.kind} {
+		if part != "*" {
+			n++
+		}
+	}
+	return n
+}
+
+// ordering places GVKs in the tiers given by Options.Ordering.
+type ordering struct {
+	patterns []orderPattern
+	// indexes holds the place in Options.Ordering of each pattern.
+	indexes []int
+	err     error
+}
+
+func newOrdering(entries []string) ordering {
+	patterns, indexes, err := parseOrdering(entries)
+	return ordering{patterns: patterns, indexes: indexes, err: err}
+}
+
+// tier returns the place in Options.Ordering of the most specific pattern matching the GVK, taking
+// the earliest of equally specific patterns, so "apps/*/Deployment" wins over "Deployment" and both
+// over "*". A GVK that matches nothing, which can only happen when the ordering has no "*", has a
+// tier of -1 and is applied first.
+func (r ordering) tier(gvk schema.GroupVersionKind) int {
+	tier, best := -1, -1
+	for i, p := range r.patterns {
+		if !p.matches(gvk) {
+			continue
+		}
+		if s := p.specificity(); s > best {
+			tier, best = r.indexes[i], s
+		}
+	}
+	return tier
+}
@@ -0,0 +1,214 @@
+package resourcecache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultOwnershipLabel is the ownership label used when Options.Owner is set without an
+// Options.OwnershipLabel.
+const DefaultOwnershipLabel = "rhc-osdk-utils/owner-uid"
+
+// AdoptionPolicy decides what happens to an existing object that the cache wants to write but that
+// is not owned by Options.Owner, for instance after the owner was deleted and recreated.
+type AdoptionPolicy string
+
+const (
+	// AdoptAlways takes over the object, adding the owner reference or ownership label.
+	AdoptAlways AdoptionPolicy = "Always"
+	// AdoptNever refuses to write the object.
+	AdoptNever AdoptionPolicy = "Never"
+	// AdoptIfLabelled takes over the object only if it carries the ownership label with the owner's
+	// UID, and refuses to write it otherwise.
+	AdoptIfLabelled AdoptionPolicy = "IfLabelled"
+)
+
+// AdoptionError is returned by ApplyAll, before anything is written, when the adoption policy
+// refuses to take over existing objects.
+type AdoptionError struct {
+	Policy  AdoptionPolicy
+	Objects []ObjectRef
+}
+
+func (e *AdoptionError) Error() string {
+	refs := make([]string, len(e.Objects))
+	for i, ref := range e.Objects {
+		refs[i] = ref.String()
+	}
+	return fmt.Sprintf("adoption policy %s refused to adopt %d objects not owned by the owner: %s", e.Policy, len(e.Objects), strings.Join(refs, ", "))
+}
+
+// ownAll ensures the configured owner on every cached object that ApplyAll writes, recording the
+// objects adopted in the result when one is given. Paused objects are not written, so they are
+// neither adopted nor refused. Every object the adoption policy refuses is listed in a single
+// AdoptionError.
+func (o *ObjectCache) ownAll(result *ApplyResult) error {
+	if o.config.options.Owner == nil {
+		return nil
+	}
+
+	refused := &AdoptionError{Policy: o.config.options.AdoptionPolicy}
+	for _, v := range o.sortedObjects().objs {
+		if v.Ident.GetWriteNow() || v.Resource.Object.GetName() == "" {
+			continue
+		}
+		if _, paused := o.paused(v.Resource.origObject); paused {
+			continue
+		}
+		adopted, err := o.ensureOwner(v.Ident, v.Resource)
+		adoptionErr := &AdoptionError{}
+		if errors.As(err, &adoptionErr) {
+			refused.Objects = append(refused.Objects, adoptionErr.Objects...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if adopted && result != nil {
+			ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object)
+			if err != nil {
+				return err
+			}
+			cr := result.cluster(ref.Cluster)
+			cr.Adopted = append(cr.Adopted, ref)
+		}
+	}
+
+	if len(refused.Objects) > 0 {
+		return refused
+	}
+	return nil
+}
+
+// ensureOwner makes the configured owner the controller of a cached object. Kubernetes only allows
+// an owner reference to an owner in the same cluster that is either cluster scoped or in the
+// object's namespace, so any other object is marked with the ownership label instead, which
+// Reconcile also matches against the owner UID. An existing object that is not yet owned is only
+// taken over if the adoption policy allows it; ensureOwner reports whether it was adopted.
+func (o *ObjectCache) ensureOwner(ident ResourceIdent, res *k8sResource) (bool, error) {
+	owner := o.config.options.Owner
+	if owner == nil {
+		return false, nil
+	}
+	if owner.GetUID() == "" {
+		return false, fmt.Errorf("cannot set owner: owner [%s/%s] has no UID", owner.GetNamespace(), owner.GetName())
+	}
+
+	obj := res.Object
+	gvk, err := o.gvkFor(obj)
+	if err != nil {
+		return false, err
+	}
+	namespaced, known, err := o.namespaced(gvk)
+	if err != nil {
+		return false, err
+	}
+	if !known {
+		namespaced = obj.GetNamespace() != ""
+	}
+
+	ownerNamespace := owner.GetNamespace()
+	local := identCluster(ident) == LocalCluster
+	useRef := local && (ownerNamespace == "" || (namespaced && obj.GetNamespace() == ownerNamespace))
+	label := o.config.options.OwnershipLabel
+	if !useRef && label == "" {
+		return false, fmt.Errorf("cannot set owner: [%s/%s] cannot reference the owner and no ownership label is set", obj.GetNamespace(), obj.GetName())
+	}
+
+	adopted := false
+	if res.Update {
+		live := res.origObject
+		labelled := label != "" && live.GetLabels()[label] == string(owner.GetUID())
+		claimed := labelled
+		if useRef {
+			claimed = hasOwnerReference(live, owner)
+		}
+		if !claimed {
+			if err := o.checkAdoption(ident, live, labelled); err != nil {
+				return false, err
+			}
+			o.log.Info("Adopting resource", "namespace", obj.GetNamespace(), "name", obj.GetName(), "kind", gvk.Kind, "cluster", identCluster(ident), "policy", o.config.options.AdoptionPolicy)
+			adopted = true
+		}
+	}
+
+	if useRef {
+		return adopted, o.setControllerReference(obj)
+	}
+
+	if local && namespaced {
+		o.log.Info("Owner is in a different namespace, using ownership label", "namespace", obj.GetNamespace(), "name", obj.GetName(), "kind", gvk.Kind, "ownerNamespace", ownerNamespace)
+	} else {
+		o.log.V(1).Info("Object cannot reference owner, using ownership label", "namespace", obj.GetNamespace(), "name", obj.GetName(), "kind", gvk.Kind, "cluster", identCluster(ident))
+	}
+	utils.UpdateLabels(obj, map[string]string{label: string(owner.GetUID())})
+	return adopted, nil
+}
+
+// checkAdoption applies the adoption policy to an existing object that is not owned by the owner,
+// returning an AdoptionError if it must not be taken over.
+func (o *ObjectCache) checkAdoption(ident ResourceIdent, live client.Object, labelled bool) error {
+	policy := o.config.options.AdoptionPolicy
+	if policy == AdoptAlways || (policy == AdoptIfLabelled && labelled) {
+		return nil
+	}
+
+	ref, err := o.refFor(ident, types.NamespacedName{Namespace: live.GetNamespace(), Name: live.GetName()}, live)
+	if err != nil {
+		return err
+	}
+	o.log.Info("Refusing to adopt resource", "namespace", live.GetNamespace(), "name", live.GetName(), "kind", ref.GVK.Kind, "cluster", ref.Cluster, "policy", policy)
+	return &AdoptionError{Policy: policy, Objects: []ObjectRef{ref}}
+}
+
+// hasOwnerReference reports whether the object holds an owner reference to the owner.
+func hasOwnerReference(obj client.Object, owner client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+// setControllerReference adds, or refreshes, a controller reference to the configured owner. An
+// object controlled by something else is left alone and an error returned.
+func (o *ObjectCache) setControllerReference(obj client.Object) error {
+	owner := o.config.options.Owner
+	ownerGVK, err := o.gvkFor(owner)
+	if err != nil {
+		return err
+	}
+
+	ref := metav1.OwnerReference{
+		APIVersion: ownerGVK.GroupVersion().String(),
+		Kind:       ownerGVK.Kind,
+		Name:       owner.GetName(),
+		UID:        owner.GetUID(),
+		Controller: utils.TruePtr(),
+	}
+
+	refs := obj.GetOwnerReferences()
+	index := -1
+	for i, existing := range refs {
+		if existing.UID == ref.UID {
+			index = i
+		} else if existing.Controller != nil && *existing.Controller {
+			return fmt.Errorf("cannot set owner: [%s/%s] is already controlled by %s [%s]", obj.GetNamespace(), obj.GetName(), existing.Kind, existing.Name)
+		}
+	}
+	if index >= 0 {
+		refs[index] = ref
+	} else {
+		refs = append(refs, ref)
+	}
+	obj.SetOwnerReferences(refs)
+	return nil
+}
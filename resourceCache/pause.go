@@ -0,0 +1,26 @@
+package resourcecache
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// paused reports whether ApplyAll, Reconcile and WriteNow updates must leave an object alone,
+// returning the reason given in the pause annotation. The annotation is looked for on
+// Options.Owner, which pauses everything, and then on the live object. When Options.Owner is not
+// set, only the live object is checked.
+func (o *ObjectCache) paused(live client.Object) (string, bool) {
+	annotation := o.config.options.PauseAnnotation
+	if annotation == "" {
+		return "", false
+	}
+	if owner := o.config.options.Owner; owner != nil {
+		if reason, ok := owner.GetAnnotations()[annotation]; ok {
+			return reason, true
+		}
+	}
+	if live == nil {
+		return "", false
+	}
+	reason, ok := live.GetAnnotations()[annotation]
+	return reason, ok
+}
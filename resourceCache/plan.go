@@ -0,0 +1,105 @@
+package resourcecache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectRef identifies a single object handled by the cache.
+type ObjectRef struct {
+	// Cluster is the name of the cluster the object lives in, LocalCluster for the local one.
+	Cluster        string
+	GVK            schema.GroupVersionKind
+	NamespacedName types.NamespacedName
+}
+
+func (r ObjectRef) String() string {
+	if r.Cluster != LocalCluster {
+		return fmt.Sprintf("%s %s (cluster %s)", r.GVK, r.NamespacedName, r.Cluster)
+	}
+	return fmt.Sprintf("%s %s", r.GVK, r.NamespacedName)
+}
+
+// Plan lists the writes ApplyAll and Reconcile would make. Creates and Updates are in apply order,
+// Deletes are sorted by GVK and name.
+type Plan struct {
+	Creates []ObjectRef
+	Updates []ObjectRef
+	Deletes []ObjectRef
+}
+
+// Plan works out what ApplyAll followed by Reconcile would do without writing anything. Objects
+// that are unchanged since Create, and WriteNow objects that have already been written, are left
+// out. The ownedUID and list options are those that would be passed to Reconcile.
+func (o *ObjectCache) Plan(ownedUID types.UID, opts ...client.ListOption) (Plan, error) {
+	return o.PlanContext(o.ctx, ownedUID, opts...)
+}
+
+// PlanContext is Plan using the given context for the lists, bounded by Timeouts.Reconcile if set.
+func (o *ObjectCache) PlanContext(ctx context.Context, ownedUID types.UID, opts ...client.ListOption) (Plan, error) {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Reconcile)
+	defer cancel()
+
+	plan := Plan{}
+
+	if err := o.ownAll(nil); err != nil {
+		return plan, err
+	}
+
+	for _, v := range o.sortedObjects().objs {
+		if v.Ident.GetWriteNow() || v.Resource.Object.GetName() == "" {
+			continue
+		}
+
+		ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object)
+		if err != nil {
+			return plan, err
+		}
+
+		if !v.Resource.Update {
+			plan.Creates = append(plan.Creates, ref)
+			continue
+		}
+
+		apply, err := needsApply(v.Resource)
+		if err != nil {
+			return plan, err
+		}
+		status, err := statusChanged(v.Resource)
+		if err != nil {
+			return plan, err
+		}
+		if apply || status {
+			plan.Updates = append(plan.Updates, ref)
+		}
+	}
+
+	for _, cluster := range o.reconciledClusters() {
+		kclient, err := o.clientFor(cluster)
+		if err != nil {
+			return plan, err
+		}
+		orphans, err := o.orphans(ctx, kclient, cluster, ownedUID, opts...)
+		if err != nil {
+			return plan, err
+		}
+		for _, obj := range orphans {
+			plan.Deletes = append(plan.Deletes, ObjectRef{
+				Cluster:        cluster,
+				GVK:            obj.GroupVersionKind(),
+				NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()},
+			})
+		}
+	}
+	sort.Slice(plan.Deletes, func(i, j int) bool {
+		return plan.Deletes[i].String() < plan.Deletes[j].String()
+	})
+
+	return plan, nil
+}
@@ -0,0 +1,136 @@
+package resourcecache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// prefetchedKind holds the objects of one GVK listed by Prefetch, along with the namespaces that
+// were listed. An empty namespace means every namespace was listed.
+type prefetchedKind struct {
+	namespaces map[string]bool
+	objects    map[types.NamespacedName]client.Object
+}
+
+func (p *prefetchedKind) covers(namespace string) bool {
+	return p.namespaces[""] || p.namespaces[namespace]
+}
+
+// Prefetch lists every possible GVK once in each of the given namespaces of the local cluster, using
+// the cache's reader, and keeps the results in memory. Later calls to Create for an object of a
+// prefetched GVK in a prefetched namespace are answered from memory instead of issuing a Get; an
+// object missing from the listing is treated as not found. Passing no namespaces lists across all
+// namespaces.
+//
+// Only GVKs that are possible at the time of the call are listed, so when StrictGVK is not set the
+// types should be registered first with AddPossibleGVKFromIdent. GVKs the API server does not serve
+// are skipped and continue to be read with Get. The prefetched state is not refreshed, it reflects
+// the cluster at the time Prefetch was called.
+func (o *ObjectCache) Prefetch(namespaces ...string) error {
+	return o.PrefetchContext(o.ctx, namespaces...)
+}
+
+// PrefetchContext is Prefetch using the given context, bounded by Timeouts.Prefetch if set.
+func (o *ObjectCache) PrefetchContext(ctx context.Context, namespaces ...string) error {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Prefetch)
+	defer cancel()
+
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	for gvk := range o.config.possibleGVKs {
+		for _, namespace := range namespaces {
+			if err := o.prefetchKind(ctx, gvk, namespace); err != nil {
+				if meta.IsNoMatchError(err) {
+					break
+				}
+				return fmt.Errorf("prefetching %s in namespace [%s]: %w", gvk, namespace, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (o *ObjectCache) prefetchKind(ctx context.Context, gvk schema.GroupVersionKind, namespace string) error {
+	list := o.newList(gvk)
+	if err := o.reader.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	kind, ok := o.prefetched[gvk]
+	if !ok {
+		kind = &prefetchedKind{
+			namespaces: make(map[string]bool),
+			objects:    make(map[types.NamespacedName]client.Object),
+		}
+		o.prefetched[gvk] = kind
+	}
+
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			return fmt.Errorf("listed item of kind %s is not a client.Object", gvk)
+		}
+		kind.objects[types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}] = obj
+	}
+	kind.namespaces[namespace] = true
+
+	return nil
+}
+
+// newList returns a typed list for the GVK when one is registered in the scheme, falling back to an
+// unstructured list.
+func (o *ObjectCache) newList(gvk schema.GroupVersionKind) client.ObjectList {
+	if obj, err := o.scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List")); err == nil {
+		if list, ok := obj.(client.ObjectList); ok {
+			return list
+		}
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk)
+	return list
+}
+
+// fetch populates the object with its live state. Objects in remote clusters are read with a Get
+// through the cluster's client. Local objects are read from the prefetched objects if their GVK and
+// namespace were prefetched and otherwise with a Get through the cache's reader. The returned
+// Updater is true if the object exists.
+func (o *ObjectCache) fetch(ctx context.Context, cluster string, nn types.NamespacedName, object client.Object) (utils.Updater, error) {
+	if cluster != LocalCluster {
+		kclient, err := o.clientFor(cluster)
+		if err != nil {
+			return false, err
+		}
+		return utils.UpdateOrErr(kclient.Get(ctx, nn, object))
+	}
+
+	gvk, err := o.gvkFor(object)
+	if err == nil {
+		if kind, ok := o.prefetched[gvk]; ok && kind.covers(nn.Namespace) {
+			stored, ok := kind.objects[nn]
+			if !ok {
+				return false, nil
+			}
+			if err := o.scheme.Convert(stored.DeepCopyObject(), object, ctx); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	return utils.UpdateOrErr(o.reader.Get(ctx, nn, object))
+}
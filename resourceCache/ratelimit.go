@@ -0,0 +1,68 @@
+package resourcecache
+
+import (
+	"context"
+	"time"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// rateLimitLogThreshold is the wait above which a throttled write is logged at the default level.
+	rateLimitLogThreshold = time.Second
+	// rateLimitDebugThreshold is the wait above which a throttled write is logged at V(1).
+	rateLimitDebugThreshold = 50 * time.Millisecond
+)
+
+// newWriteRateLimiter returns the limiter configured in the options, building a token bucket from
+// WriteQPS and WriteBurst if no limiter was given. It returns nil when writes are not limited.
+func newWriteRateLimiter(options Options) flowcontrol.RateLimiter {
+	if options.WriteRateLimiter != nil {
+		return options.WriteRateLimiter
+	}
+	if options.WriteQPS <= 0 {
+		return nil
+	}
+	burst := options.WriteBurst
+	if burst < 1 {
+		burst = 1
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(options.WriteQPS, burst)
+}
+
+// throttle blocks until the write rate limiter allows another write, or the context is done. The
+// time spent waiting is logged and added to the cache's total.
+func (o *ObjectCache) throttle(ctx context.Context, verb string) error {
+	limiter := o.config.options.WriteRateLimiter
+	if limiter == nil {
+		return nil
+	}
+
+	start := time.Now()
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+	wait := time.Since(start)
+	o.rateLimitWait += wait
+
+	if wait >= rateLimitLogThreshold {
+		o.log.Info("Waited for write rate limiter", "verb", verb, "wait", wait.String())
+	} else if wait >= rateLimitDebugThreshold {
+		o.log.V(1).Info("Waited for write rate limiter", "verb", verb, "wait", wait.String())
+	}
+	return nil
+}
+
+// writeVerb names the write an Updater makes, for logging.
+func writeVerb(update utils.Updater) string {
+	if update {
+		return "update"
+	}
+	return "create"
+}
+
+// RateLimitWait returns the total time the cache has spent waiting for the write rate limiter.
+func (o *ObjectCache) RateLimitWait() time.Duration {
+	return o.rateLimitWait
+}
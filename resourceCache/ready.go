@@ -0,0 +1,215 @@
+package resourcecache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/resources"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultReadinessBackoff is the backoff between readiness checks used when
+// Options.ReadinessBackoff is not set.
+var DefaultReadinessBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+	Cap:      30 * time.Second,
+}
+
+// ReadyFunc reports whether a live object is ready for the objects applied after it.
+type ReadyFunc func(obj *unstructured.Unstructured) (bool, error)
+
+// ReadinessGate holds ApplyAll back, once the tier of the ordering holding the objects it selects
+// has been applied, until those objects are ready.
+type ReadinessGate struct {
+	// GVK is a pattern, in the syntax of Options.Ordering, selecting the cached objects gated.
+	GVK string
+	// Namespace and Name, when set, narrow the objects gated.
+	Namespace string
+	Name      string
+	// Ready reports whether an object is ready.
+	Ready ReadyFunc
+}
+
+// NotReadyError is returned by ApplyAll when gated objects were not ready in time. The objects in
+// later tiers are listed as Unapplied; the reconciler should requeue after RequeueAfter.
+type NotReadyError struct {
+	Cluster      string
+	Objects      []ObjectRef
+	RequeueAfter time.Duration
+}
+
+func (e *NotReadyError) Error() string {
+	refs := make([]string, len(e.Objects))
+	for i, ref := range e.Objects {
+		refs[i] = ref.String()
+	}
+	return fmt.Sprintf("%d objects not ready, requeue after %s: %s", len(e.Objects), e.RequeueAfter, strings.Join(refs, ", "))
+}
+
+// ConditionsReady returns a ReadyFunc following the model of resources.Resource.IsReady: an object
+// is ready when it has a condition matching one of the requirements and its
+// status.observedGeneration has caught up with its generation.
+func ConditionsReady(requirements ...resources.ResourceConditionReadyRequirements) ReadyFunc {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		res := resources.MakeResource(*obj)
+		for _, requirement := range requirements {
+			res.AddReadyRequirements(requirement)
+		}
+		return res.IsReady(), nil
+	}
+}
+
+// GenerationObserved returns a ReadyFunc for which an object is ready once its
+// status.observedGeneration has caught up with its generation.
+func GenerationObserved() ReadyFunc {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		res := resources.MakeResource(*obj)
+		return res.Metadata.Generation <= res.Status.ObservedGeneration, nil
+	}
+}
+
+// readinessGate is a ReadinessGate with its GVK pattern parsed.
+type readinessGate struct {
+	ReadinessGate
+	pattern orderPattern
+}
+
+// readinessGates holds the parsed Options.ReadinessGates.
+type readinessGates struct {
+	gates []readinessGate
+	err   error
+}
+
+func newReadinessGates(gates []ReadinessGate) readinessGates {
+	var parsed []readinessGate
+	var errs []error
+	for _, gate := range gates {
+		pattern, err := parseOrderPattern(gate.GVK)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("readiness gate: %w", err))
+			continue
+		}
+		if gate.Ready == nil {
+			errs = append(errs, fmt.Errorf("readiness gate [%s]: no Ready func", gate.GVK))
+			continue
+		}
+		parsed = append(parsed, readinessGate{ReadinessGate: gate, pattern: pattern})
+	}
+	return readinessGates{gates: parsed, err: joinErrors(errs)}
+}
+
+// gatedObject is a cached object that must be ready before the next tier is applied.
+type gatedObject struct {
+	ref   ObjectRef
+	ready []ReadyFunc
+}
+
+// gated returns the objects of a tier that have readiness gates.
+func (o *ObjectCache) gated(objs []ObjectToApply) ([]gatedObject, error) {
+	var gated []gatedObject
+	for _, v := range objs {
+		ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object)
+		if err != nil {
+			return nil, err
+		}
+		obj := gatedObject{ref: ref}
+		for _, gate := range o.config.gates.gates {
+			if !gate.pattern.matches(ref.GVK) {
+				continue
+			}
+			if gate.Namespace != "" && gate.Namespace != ref.NamespacedName.Namespace {
+				continue
+			}
+			if gate.Name != "" && gate.Name != ref.NamespacedName.Name {
+				continue
+			}
+			obj.ready = append(obj.ready, gate.Ready)
+		}
+		if len(obj.ready) > 0 {
+			gated = append(gated, obj)
+		}
+	}
+	return gated, nil
+}
+
+// waitReady polls the gated objects of a tier that has just been applied, backing off between
+// checks, until they are all ready. When Timeouts.Readiness is zero they are checked once. A
+// NotReadyError is returned if they are not ready in time.
+func (o *ObjectCache) waitReady(ctx context.Context, cluster string, objs []ObjectToApply) error {
+	gated, err := o.gated(objs)
+	if err != nil || len(gated) == 0 {
+		return err
+	}
+	kclient, err := o.clientFor(cluster)
+	if err != nil {
+		return err
+	}
+
+	timeout := o.config.options.Timeouts.Readiness
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := o.config.options.ReadinessBackoff
+	for {
+		var notReady []ObjectRef
+		for _, g := range gated {
+			ready, err := o.objectReady(ctx, kclient, g)
+			if err != nil {
+				return err
+			}
+			if !ready {
+				notReady = append(notReady, g.ref)
+			}
+		}
+		if len(notReady) == 0 {
+			o.log.V(1).Info("Gated objects ready", "cluster", cluster, "objects", len(gated))
+			return nil
+		}
+
+		delay := backoff.Step()
+		if timeout <= 0 {
+			return &NotReadyError{Cluster: cluster, Objects: notReady, RequeueAfter: delay}
+		}
+		o.log.Info("Waiting for objects to be ready", "cluster", cluster, "notReady", len(notReady), "retry", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-waitCtx.Done():
+			timer.Stop()
+			return &NotReadyError{Cluster: cluster, Objects: notReady, RequeueAfter: delay}
+		case <-timer.C:
+		}
+	}
+}
+
+// objectReady reads a gated object and runs its ready funcs. An object that cannot be found is not
+// ready.
+func (o *ObjectCache) objectReady(ctx context.Context, kclient client.Client, g gatedObject) (bool, error) {
+	live := newUnstructured(g.ref.GVK)
+	if err := kclient.Get(ctx, g.ref.NamespacedName, live); err != nil {
+		if k8serr.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("[%s]: %w", g.ref, err)
+	}
+	for _, ready := range g.ready {
+		ok, err := ready(live)
+		if err != nil {
+			return false, fmt.Errorf("[%s]: %w", g.ref, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -3,8 +3,10 @@ package resourcecache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/RedHatInsights/go-difflib/difflib"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
@@ -14,14 +16,14 @@ import (
 	core "k8s.io/api/core/v1"
 
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
-
-	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/util/flowcontrol"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -33,8 +35,43 @@ type ResourceIdent interface {
 	GetWriteNow() bool
 }
 
+// ImmutablePolicyIdent is implemented by idents that choose how an object whose immutable fields
+// changed is handled. Idents without it use the zero ImmutablePolicy and fail the apply.
+type ImmutablePolicyIdent interface {
+	GetImmutablePolicy() ImmutablePolicy
+}
+
+// ClusterIdent is implemented by idents that write their objects to a cluster registered in
+// Options.Clusters. Idents without it write to LocalCluster.
+type ClusterIdent interface {
+	GetCluster() string
+}
+
+// identImmutablePolicy returns the ImmutablePolicy of an ident, if it has one.
+func identImmutablePolicy(ident ResourceIdent) ImmutablePolicy {
+	if i, ok := ident.(ImmutablePolicyIdent); ok {
+		return i.GetImmutablePolicy()
+	}
+	return ImmutablePolicy{}
+}
+
+// identCluster returns the cluster an ident's objects are written to.
+func identCluster(ident ResourceIdent) string {
+	if i, ok := ident.(ClusterIdent); ok {
+		return i.GetCluster()
+	}
+	return LocalCluster
+}
+
 type ResourceOptions struct {
-	WriteNow bool
+	WriteNow  bool
+	Immutable ImmutablePolicy
+	// Cluster names the cluster, registered in Options.Clusters, the objects are written to. The
+	// default is the cluster of the client passed to NewObjectCache.
+	Cluster string
+	// NameTemplate is the template NameFor generates the names of a multi ident's objects from.
+	// Defaults to DefaultNameTemplate.
+	NameTemplate string
 }
 
 // ResourceIdent is a simple struct declaring a providers identifier and the type of resource to be
@@ -42,10 +79,12 @@ type ResourceOptions struct {
 // they all come from the same provider and have the same purpose. Think a list of Jobs created by
 // a Job creator.
 type ResourceIdentSingle struct {
-	Provider string
-	Purpose  string
-	Type     client.Object
-	WriteNow bool
+	Provider  string
+	Purpose   string
+	Type      client.Object
+	WriteNow  bool
+	Immutable ImmutablePolicy
+	Cluster   string
 }
 
 func (r ResourceIdentSingle) GetProvider() string {
@@ -64,15 +103,26 @@ func (r ResourceIdentSingle) GetWriteNow() bool {
 	return r.WriteNow
 }
 
+func (r ResourceIdentSingle) GetImmutablePolicy() ImmutablePolicy {
+	return r.Immutable
+}
+
+func (r ResourceIdentSingle) GetCluster() string {
+	return r.Cluster
+}
+
 // ResourceIdent is a simple struct declaring a providers identifier and the type of resource to be
 // put into the cache. It functions as an identifier allowing multiple objects to be returned if
 // they all come from the same provider and have the same purpose. Think a list of Jobs created by
 // a Job creator.
 type ResourceIdentMulti struct {
-	Provider string
-	Purpose  string
-	Type     client.Object
-	WriteNow bool
+	Provider     string
+	Purpose      string
+	Type         client.Object
+	WriteNow     bool
+	Immutable    ImmutablePolicy
+	Cluster      string
+	NameTemplate string
 }
 
 func (r ResourceIdentMulti) GetProvider() string {
@@ -91,6 +141,14 @@ func (r ResourceIdentMulti) GetWriteNow() bool {
 	return r.WriteNow
 }
 
+func (r ResourceIdentMulti) GetImmutablePolicy() ImmutablePolicy {
+	return r.Immutable
+}
+
+func (r ResourceIdentMulti) GetCluster() string {
+	return r.Cluster
+}
+
 var secretCompare schema.GroupVersionKind
 
 func init() {
@@ -102,28 +160,43 @@ func init() {
 // NewSingleResourceIdent is a helper function that returns a ResourceIdent object.
 func NewSingleResourceIdent(provider string, purpose string, object client.Object, opts ...ResourceOptions) ResourceIdentSingle {
 	writeNow := false
+	immutable := ImmutablePolicy{}
+	cluster := LocalCluster
 	for _, opt := range opts {
 		writeNow = opt.WriteNow
+		immutable = opt.Immutable
+		cluster = opt.Cluster
 	}
 	return ResourceIdentSingle{
-		Provider: provider,
-		Purpose:  purpose,
-		Type:     object,
-		WriteNow: writeNow,
+		Provider:  provider,
+		Purpose:   purpose,
+		Type:      object,
+		WriteNow:  writeNow,
+		Immutable: immutable,
+		Cluster:   cluster,
 	}
 }
 
 // NewMultiResourceIdent is a helper function that returns a ResourceIdent object.
 func NewMultiResourceIdent(provider string, purpose string, object client.Object, opts ...ResourceOptions) ResourceIdentMulti {
 	writeNow := false
+	immutable := ImmutablePolicy{}
+	cluster := LocalCluster
+	nameTemplate := ""
 	for _, opt := range opts {
 		writeNow = opt.WriteNow
+		immutable = opt.Immutable
+		cluster = opt.Cluster
+		nameTemplate = opt.NameTemplate
 	}
 	return ResourceIdentMulti{
-		Provider: provider,
-		Purpose:  purpose,
-		Type:     object,
-		WriteNow: writeNow,
+		Provider:     provider,
+		Purpose:      purpose,
+		Type:         object,
+		WriteNow:     writeNow,
+		Immutable:    immutable,
+		Cluster:      cluster,
+		NameTemplate: nameTemplate,
 	}
 }
 
@@ -131,12 +204,25 @@ func NewMultiResourceIdent(provider string, purpose string, object client.Object
 // as well as a Data structure that is used to hold the K8sResources.
 type ObjectCache struct {
 	data            map[ResourceIdent]map[types.NamespacedName]*k8sResource
-	resourceTracker map[schema.GroupVersionKind]map[types.NamespacedName]bool
+	resourceTracker map[trackerKey]map[types.NamespacedName]bool
 	scheme          *runtime.Scheme
 	client          client.Client
+	reader          client.Reader
+	mapper          meta.RESTMapper
+	prefetched      map[schema.GroupVersionKind]*prefetchedKind
 	ctx             context.Context
 	log             logr.Logger
 	config          *CacheConfig
+	lastApply       ApplyResult
+	lastReconcile   ApplyResult
+	rateLimitWait   time.Duration
+	snapshots       map[SnapshotToken]*cacheState
+	snapshotSeq     uint64
+	// identOrder records the order in which idents were first created, which is the apply order
+	// of objects sharing a tier.
+	identOrder map[ResourceIdent]int
+	// names records the names generated by NameFor, by logical key.
+	names map[ResourceIdentMulti]map[NameKey]types.NamespacedName
 }
 
 func NewCacheConfig(scheme *runtime.Scheme, possibleGVKs, protectedGVKs GVKMap, options ...Options) *CacheConfig {
@@ -160,11 +246,35 @@ func NewCacheConfig(scheme *runtime.Scheme, possibleGVKs, protectedGVKs GVKMap,
 		}
 	}
 
+	if optionObject.ImmutableFields == nil {
+		optionObject.ImmutableFields = DefaultImmutableFields
+	}
+
+	if optionObject.Validators == nil {
+		optionObject.Validators = DefaultValidators
+	}
+
+	if optionObject.Owner != nil && optionObject.OwnershipLabel == "" {
+		optionObject.OwnershipLabel = DefaultOwnershipLabel
+	}
+
+	if optionObject.ReadinessBackoff.Duration == 0 {
+		optionObject.ReadinessBackoff = DefaultReadinessBackoff
+	}
+
+	if optionObject.AdoptionPolicy == "" {
+		optionObject.AdoptionPolicy = AdoptAlways
+	}
+
+	optionObject.WriteRateLimiter = newWriteRateLimiter(optionObject)
+
 	return &CacheConfig{
 		possibleGVKs:  possibleGVKs,
 		protectedGVKs: protectedGVKs,
 		scheme:        scheme,
 		options:       optionObject,
+		ordering:      newOrdering(optionObject.Ordering),
+		gates:         newReadinessGates(optionObject.ReadinessGates),
 	}
 }
 
@@ -176,9 +286,72 @@ type DebugOptions struct {
 }
 
 type Options struct {
-	StrictGVK    bool
+	StrictGVK bool
+	// Ordering lists GVK patterns in the order their objects are applied: "*", "Kind",
+	// "group/Kind" or "group/version/Kind", where any part may be "*". Each object takes the place
+	// of the most specific pattern it matches, and Teardown works through the places in reverse.
+	// Defaults to "*", "Deployment", "Job", "CronJob".
 	Ordering     []string
 	DebugOptions DebugOptions
+	// ImmutableFields lists, per GVK, the fields that cannot be changed on an existing object.
+	// Defaults to DefaultImmutableFields.
+	ImmutableFields map[schema.GroupVersionKind][]ImmutableFieldRule
+	// Reader is used by Create and Prefetch to read the live state of objects, for instance the
+	// manager's cached client. Defaults to the client passed to NewObjectCache.
+	Reader client.Reader
+	// RESTMapper resolves the scope of kinds, and the version of unstructured objects that do not
+	// set one. Defaults to the RESTMapper of the client passed to NewObjectCache.
+	RESTMapper meta.RESTMapper
+	// Clusters is a registry of clients for remote clusters, keyed by the name idents use in
+	// ResourceOptions.Cluster.
+	Clusters map[string]client.Client
+	// OwnershipLabel is a label whose value is matched against the owner UID by Reconcile, in
+	// addition to owner references. Objects in remote clusters cannot hold an owner reference to a
+	// local object, so remote clusters are only reconciled when this is set. Defaults to
+	// DefaultOwnershipLabel when Owner is set, and is otherwise unset.
+	OwnershipLabel string
+	// Owner, when set, is made the controller of every object the cache writes. Objects that cannot
+	// hold an owner reference to it, because they are cluster scoped, in another namespace or in a
+	// remote cluster, get the ownership label instead.
+	Owner client.Object
+	// AdoptionPolicy decides whether ApplyAll takes over existing objects not yet owned by Owner.
+	// Defaults to AdoptAlways.
+	AdoptionPolicy AdoptionPolicy
+	// PauseAnnotation names an annotation that stops ApplyAll, Reconcile and WriteNow updates from
+	// touching objects. Set on Owner it pauses everything; set on a live object it pauses just that
+	// object. Its value is logged as the reason. Without Owner only live objects can be paused.
+	PauseAnnotation string
+	// Validators lists, per GVK, extra checks run by Validate after the metadata has been checked.
+	// Defaults to DefaultValidators.
+	Validators map[schema.GroupVersionKind][]Validator
+	// SkipValidation stops ApplyAll from running Validate before writing.
+	SkipValidation bool
+	// Transactional makes ApplyAll undo the writes it has made in a cluster when a later write in
+	// that cluster fails, reverting updated objects and deleting created ones in reverse order.
+	Transactional bool
+	// Timeouts bounds the time spent in each operation.
+	Timeouts Timeouts
+	// WriteQPS and WriteBurst configure a token bucket limiting the creates, updates, status
+	// patches and deletes issued by the cache. Writes are not limited when WriteQPS is zero. Each
+	// CacheConfig gets its own bucket, so the budget is per cache; use WriteRateLimiter to limit
+	// an operator that builds a config per reconcile.
+	WriteQPS   float32
+	WriteBurst int
+	// WriteRateLimiter replaces the token bucket built from WriteQPS and WriteBurst. Passing the
+	// same limiter to every cache an operator creates limits the operator as a whole.
+	WriteRateLimiter flowcontrol.RateLimiter
+	// ReadinessGates hold ApplyAll back after a tier of the ordering until the gated objects in it
+	// are ready, polling with ReadinessBackoff for up to Timeouts.Readiness.
+	ReadinessGates []ReadinessGate
+	// ReadinessBackoff spaces out the readiness checks. Defaults to DefaultReadinessBackoff.
+	ReadinessBackoff wait.Backoff
+	// ChangeTracker, when set, carries state across reconciles so ApplyAll skips objects that have
+	// not changed since they were last applied, and Reconcile fetches the objects dropped from the
+	// cache instead of listing every possible GVK.
+	ChangeTracker *ChangeTracker
+	// History, when set, records a summary of each ApplyAll and Reconcile. ApplyAll is recorded
+	// under the UID of Owner, and not at all without one, Reconcile under the UID it is given.
+	History *History
 }
 
 type CacheConfig struct {
@@ -186,6 +359,8 @@ type CacheConfig struct {
 	protectedGVKs GVKMap
 	scheme        *runtime.Scheme
 	options       Options
+	ordering      ordering
+	gates         readinessGates
 }
 
 type k8sResource struct {
@@ -214,6 +389,16 @@ func NewObjectCache(ctx context.Context, kclient client.Client, logger *logr.Log
 
 	var log logr.Logger
 
+	var reader client.Reader = kclient
+	if config.options.Reader != nil {
+		reader = config.options.Reader
+	}
+
+	mapper := config.options.RESTMapper
+	if mapper == nil && kclient != nil {
+		mapper = kclient.RESTMapper()
+	}
+
 	if logger == nil {
 		log = logr.Discard()
 	} else {
@@ -223,16 +408,20 @@ func NewObjectCache(ctx context.Context, kclient client.Client, logger *logr.Log
 	return ObjectCache{
 		scheme:          config.scheme,
 		client:          kclient,
+		reader:          reader,
+		mapper:          mapper,
+		prefetched:      make(map[schema.GroupVersionKind]*prefetchedKind),
 		ctx:             ctx,
 		data:            make(map[ResourceIdent]map[types.NamespacedName]*k8sResource),
-		resourceTracker: make(map[schema.GroupVersionKind]map[types.NamespacedName]bool),
+		resourceTracker: make(map[trackerKey]map[types.NamespacedName]bool),
+		identOrder:      make(map[ResourceIdent]int),
 		log:             log,
 		config:          config,
 	}
 }
 
 func (o *ObjectCache) registerGVK(obj client.Object) {
-	gvk, _ := utils.GetKindFromObj(o.scheme, obj)
+	gvk, _ := o.gvkFor(obj)
 	if _, ok := o.config.possibleGVKs[gvk]; !ok {
 		o.config.possibleGVKs[gvk] = true
 		if o.config.options.DebugOptions.Registration {
@@ -241,12 +430,35 @@ func (o *ObjectCache) registerGVK(obj client.Object) {
 	}
 }
 
-// Create first attempts to fetch the object from k8s for initial population. If this fails, the
-// blank object is stored in the cache it is imperative that the user of this function call Create
-// before modifying the obejct they wish to be placed in the cache.
+// Create first attempts to fetch the object from k8s for initial population, using the prefetched
+// objects when available and the configured reader otherwise. If this fails, the blank object is
+// stored in the cache it is imperative that the user of this function call Create before modifying
+// the obejct they wish to be placed in the cache.
+//
+// An *unstructured.Unstructured object that has no kind set takes its GVK from the ident's type.
 func (o *ObjectCache) Create(resourceIdent ResourceIdent, nn types.NamespacedName, object client.Object) error {
+	return o.CreateContext(o.ctx, resourceIdent, nn, object)
+}
+
+// CreateContext is Create using the given context for the read, bounded by Timeouts.Create if set.
+func (o *ObjectCache) CreateContext(ctx context.Context, resourceIdent ResourceIdent, nn types.NamespacedName, object client.Object) error {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Create)
+	defer cancel()
+
+	if u, ok := object.(*unstructured.Unstructured); ok {
+		var source runtime.Object = u
+		if u.GetKind() == "" {
+			source = resourceIdent.GetType()
+		}
+		gvk, err := o.gvkFor(source)
+		if err != nil {
+			return err
+		}
+		u.SetGroupVersionKind(gvk)
+	}
+
 	if o.config.options.StrictGVK {
-		gvk, err := utils.GetKindFromObj(o.scheme, object)
+		gvk, err := o.gvkFor(object)
 		if err != nil {
 			return fmt.Errorf("object type not in schema")
 		}
@@ -256,7 +468,13 @@ func (o *ObjectCache) Create(resourceIdent ResourceIdent, nn types.NamespacedNam
 	} else {
 		o.registerGVK(object)
 	}
-	update, err := utils.UpdateOrErr(o.client.Get(o.ctx, nn, object))
+	if gvk, err := o.gvkFor(object); err == nil {
+		if err := o.checkScope(gvk, nn); err != nil {
+			return err
+		}
+	}
+
+	update, err := o.fetch(ctx, identCluster(resourceIdent), nn, object)
 
 	if err != nil {
 		return err
@@ -267,11 +485,11 @@ func (o *ObjectCache) Create(resourceIdent ResourceIdent, nn types.NamespacedNam
 	}
 
 	var gvk, obGVK schema.GroupVersionKind
-	if gvk, err = utils.GetKindFromObj(o.scheme, resourceIdent.GetType()); err != nil {
+	if gvk, err = o.gvkFor(resourceIdent.GetType()); err != nil {
 		return err
 	}
 
-	if obGVK, err = utils.GetKindFromObj(o.scheme, object); err != nil {
+	if obGVK, err = o.gvkFor(object); err != nil {
 		return err
 	}
 
@@ -279,15 +497,19 @@ func (o *ObjectCache) Create(resourceIdent ResourceIdent, nn types.NamespacedNam
 		return fmt.Errorf("create: resourceIdent type does not match runtime object [%s] [%s] [%s]", nn, gvk, obGVK)
 	}
 
-	if _, ok := o.resourceTracker[gvk]; !ok {
-		o.resourceTracker[gvk] = map[types.NamespacedName]bool{nn: true}
+	key := trackerKey{Cluster: identCluster(resourceIdent), GVK: gvk}
+	if _, ok := o.resourceTracker[key]; !ok {
+		o.resourceTracker[key] = map[types.NamespacedName]bool{nn: true}
 	}
 
-	o.resourceTracker[gvk][nn] = true
+	o.resourceTracker[key][nn] = true
 
 	if _, ok := o.data[resourceIdent]; !ok {
 		o.data[resourceIdent] = make(map[types.NamespacedName]*k8sResource)
 	}
+	if _, ok := o.identOrder[resourceIdent]; !ok {
+		o.identOrder[resourceIdent] = len(o.identOrder)
+	}
 
 	var jsonData []byte
 	if o.config.options.DebugOptions.Create || o.config.options.DebugOptions.Apply {
@@ -325,6 +547,12 @@ func (o *ObjectCache) Create(resourceIdent ResourceIdent, nn types.NamespacedNam
 // Update takes the item and tries to update the version in the cache. This will fail if the item is
 // not in the cache. A previous provider should have "created" the item before it can be updated.
 func (o *ObjectCache) Update(resourceIdent ResourceIdent, object client.Object) error {
+	return o.UpdateContext(o.ctx, resourceIdent, object)
+}
+
+// UpdateContext is Update using the given context for the immediate write of WriteNow idents,
+// bounded by Timeouts.Update if set.
+func (o *ObjectCache) UpdateContext(ctx context.Context, resourceIdent ResourceIdent, object client.Object) error {
 	if _, ok := o.data[resourceIdent]; !ok {
 		return fmt.Errorf("object cache not found, cannot update")
 	}
@@ -340,11 +568,11 @@ func (o *ObjectCache) Update(resourceIdent ResourceIdent, object client.Object)
 	}
 
 	var gvk, obGVK schema.GroupVersionKind
-	if gvk, err = utils.GetKindFromObj(o.scheme, resourceIdent.GetType()); err != nil {
+	if gvk, err = o.gvkFor(resourceIdent.GetType()); err != nil {
 		return err
 	}
 
-	if obGVK, err = utils.GetKindFromObj(o.scheme, object); err != nil {
+	if obGVK, err = o.gvkFor(object); err != nil {
 		return err
 	}
 
@@ -365,39 +593,18 @@ func (o *ObjectCache) Update(resourceIdent ResourceIdent, object client.Object)
 	}
 
 	if resourceIdent.GetWriteNow() {
-		i := o.data[resourceIdent][nn]
-
-		if o.config.options.DebugOptions.Apply {
-			jsonData, _ := json.MarshalIndent(i.Object, "", "  ")
-			diff := difflib.UnifiedDiff{
-				A:        difflib.SplitLines(string(jsonData)),
-				B:        difflib.SplitLines(i.jsonData),
-				FromFile: "old",
-				ToFile:   "new",
-				Context:  3,
-			}
-			text, _ := difflib.GetUnifiedDiffString(diff)
-			if i.Object.GetObjectKind().GroupVersionKind() == secretCompare {
-				o.log.Info("Update diff", "diff", "hidden", "type", "update", "resType", i.Object.GetObjectKind().GroupVersionKind().Kind, "name", nn.Name, "namespace", nn.Namespace)
-			} else {
-				o.log.Info("Update diff", "diff", text, "type", "update", "resType", i.Object.GetObjectKind().GroupVersionKind().Kind, "name", nn.Name, "namespace", nn.Namespace)
-			}
+		res := o.data[resourceIdent][nn]
+		if reason, paused := o.paused(res.origObject); paused {
+			o.log.Info("PAUSED resource ", "namespace", nn.Namespace, "name", nn.Name, "provider", resourceIdent.GetProvider(), "purpose", resourceIdent.GetPurpose(), "cluster", identCluster(resourceIdent), "reason", reason)
+			return nil
 		}
-
-		if !equality.Semantic.DeepEqual(i.origObject, i.Object) || !bool(i.Update) {
-			o.log.Info("INSTANT APPLY resource ", "namespace", nn.Namespace, "name", nn.Name, "provider", resourceIdent.GetProvider(), "purpose", resourceIdent.GetPurpose(), "kind", object.GetObjectKind().GroupVersionKind().Kind, "update", i.Update, "skipped", false)
-
-			if err := i.Update.Apply(o.ctx, o.client, i.Object); err != nil {
-				return err
-			}
-		} else {
-			o.log.Info("INSTANT APPLY resource (skipped)", "namespace", nn.Namespace, "name", nn.Name, "provider", resourceIdent.GetProvider(), "purpose", resourceIdent.GetPurpose(), "kind", object.GetObjectKind().GroupVersionKind().Kind, "update", i.Update, "skipped", true)
+		ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Update)
+		defer cancel()
+		if _, err := o.ensureOwner(resourceIdent, res); err != nil {
+			return err
 		}
-
-		if i.Status {
-			if err := o.client.Status().Update(o.ctx, i.Object); err != nil {
-				return err
-			}
+		if _, err := o.applyObject(ctx, resourceIdent, nn, res, "INSTANT APPLY"); err != nil {
+			return err
 		}
 	}
 
@@ -424,7 +631,7 @@ func (o *ObjectCache) Get(resourceIdent ResourceIdent, object client.Object, nn
 	if _, ok := resourceIdent.(ResourceIdentSingle); ok {
 		oMap := o.data[resourceIdent]
 		for _, v := range oMap {
-			if err := o.scheme.Convert(v.Object, object, o.ctx); err != nil {
+			if err := o.scheme.Convert(v.Object.DeepCopyObject(), object, o.ctx); err != nil {
 				return err
 			}
 			object.GetObjectKind().SetGroupVersionKind(v.Object.GetObjectKind().GroupVersionKind())
@@ -434,7 +641,7 @@ func (o *ObjectCache) Get(resourceIdent ResourceIdent, object client.Object, nn
 		if !ok {
 			return fmt.Errorf("object not found")
 		}
-		if err := o.scheme.Convert(v.Object, object, o.ctx); err != nil {
+		if err := o.scheme.Convert(v.Object.DeepCopyObject(), object, o.ctx); err != nil {
 			return err
 		}
 		object.GetObjectKind().SetGroupVersionKind(v.Object.GetObjectKind().GroupVersionKind())
@@ -452,14 +659,14 @@ func (o *ObjectCache) List(resourceIdent ResourceIdentMulti, object runtime.Obje
 
 	for _, v := range oMap {
 		uobj := unstructured.Unstructured{}
-		err := o.scheme.Convert(v.Object, &uobj, o.ctx)
+		err := o.scheme.Convert(v.Object.DeepCopyObject(), &uobj, o.ctx)
 		uobj.SetGroupVersionKind(v.Object.GetObjectKind().GroupVersionKind())
 		if err != nil {
 			return fmt.Errorf("d: %s", err)
 		}
 		uList.Items = append(uList.Items, uobj)
 	}
-	err := runtime.DefaultUnstructuredConverter.FromUnstructured(uList.UnstructuredContent(), object)
+	err := fromContent(uList.UnstructuredContent(), object)
 
 	if err != nil {
 		return err
@@ -467,7 +674,9 @@ func (o *ObjectCache) List(resourceIdent ResourceIdentMulti, object runtime.Obje
 	return nil
 }
 
-// Status marks the object for having a status update
+// Status marks the object for having a status update. The status is applied with a patch after the
+// main object has been written, and changes made only to the status do not cause the main object to
+// be written.
 func (o *ObjectCache) Status(resourceIdent ResourceIdent, object client.Object) error {
 	if _, ok := o.data[resourceIdent]; !ok {
 		return fmt.Errorf("object cache not found, cannot update")
@@ -479,6 +688,10 @@ func (o *ObjectCache) Status(resourceIdent ResourceIdent, object client.Object)
 		return err
 	}
 
+	if _, ok := o.data[resourceIdent][nn]; !ok {
+		return fmt.Errorf("object not found in cache, cannot mark for status update")
+	}
+
 	o.data[resourceIdent][nn].Status = true
 
 	return nil
@@ -490,53 +703,67 @@ type ObjectToApply struct {
 	Resource       *k8sResource
 }
 
-// Define a collect type that implements the sort.Interface
+// objectsToApply holds cached objects in the order they are applied, as built by sortedObjects.
 type objectsToApply struct {
-	objs   []ObjectToApply
-	scheme *runtime.Scheme
-	order  []string
+	objs []ObjectToApply
 }
 
-func indexOf(elem string, data []string) int {
-	for k, v := range data {
-		if elem == v {
-			return k
-		}
-	}
-	return -1
+// ApplyAll takes all the items in the cache and tries to apply them, given the boolean by the
+// update field on the internal resource. If the update is true, then the object will by applied, if
+// it is false, then the object will be created.
+//
+// Each cluster is applied in turn, keeping the configured ordering within it. An error stops the
+// cluster it happened in, but not the others; LastApplyResult reports the outcome per cluster.
+func (o *ObjectCache) ApplyAll() error {
+	return o.ApplyAllContext(o.ctx)
 }
 
-func (u objectsToApply) Len() int {
-	return len(u.objs)
+// ApplyAllContext is ApplyAll using the given context, bounded by Timeouts.ApplyAll if set. When the
+// context is done, ApplyAll stops before the next object and returns the context's error; the
+// objects that were not written are listed in LastApplyResult as Unapplied.
+func (o *ObjectCache) ApplyAllContext(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.ApplyAll)
+	defer cancel()
+
+	start := time.Now()
+	o.lastApply = newApplyResult()
+	err := o.applyAll(ctx)
+	o.record("apply", o.historyOwner(), start, o.lastApply, err)
+	return err
 }
 
-func (u objectsToApply) Swap(i, j int) {
-	u.objs[i], u.objs[j] = u.objs[j], u.objs[i]
-}
+func (o *ObjectCache) applyAll(ctx context.Context) error {
+	if err := o.config.ordering.err; err != nil {
+		return err
+	}
+
+	if err := o.config.gates.err; err != nil {
+		return err
+	}
 
-func (u objectsToApply) Less(i, j int) bool {
-	k1 := "*"
-	gvk, err := utils.GetKindFromObj(u.scheme, u.objs[i].Ident.GetType())
-	if err == nil {
-		k1 = gvk.Kind
+	if err := o.ownAll(&o.lastApply); err != nil {
+		return err
 	}
 
-	k2 := "*"
-	gvk, err = utils.GetKindFromObj(u.scheme, u.objs[j].Ident.GetType())
-	if err == nil {
-		k2 = gvk.Kind
+	if !o.config.options.SkipValidation {
+		if err := o.Validate(); err != nil {
+			return err
+		}
 	}
-	i1 := indexOf(k1, u.order)
-	i2 := indexOf(k2, u.order)
-	return i1 < i2
+
+	return o.applyResourceCache(ctx, o.sortedObjects())
 }
 
-// ApplyAll takes all the items in the cache and tries to apply them, given the boolean by the
-// update field on the internal resource. If the update is true, then the object will by applied, if
-// it is false, then the object will be created.
-func (o *ObjectCache) ApplyAll() error {
-	dataToSort := objectsToApply{scheme: o.scheme, order: o.config.options.Ordering}
+// sortedObjects returns every cached object in the order it should be applied, grouped by cluster
+// and then by tier of the ordering. Within a tier, objects keep the order in which their idents were
+// first created, and the objects of an ident are sorted by namespace and name, so the order is
+// reproducible.
+func (o *ObjectCache) sortedObjects() objectsToApply {
+	dataToSort := objectsToApply{}
+	tiers := make(map[ResourceIdent]int, len(o.data))
 	for res := range o.data {
+		gvk, _ := o.gvkFor(res.GetType())
+		tiers[res] = o.config.ordering.tier(gvk)
 		for nn := range o.data[res] {
 			dataToSort.objs = append(dataToSort.objs, ObjectToApply{
 				Ident:          res,
@@ -546,57 +773,263 @@ func (o *ObjectCache) ApplyAll() error {
 		}
 	}
 
-	sort.Sort(dataToSort)
+	sort.SliceStable(dataToSort.objs, func(i, j int) bool {
+		a, b := dataToSort.objs[i], dataToSort.objs[j]
+		if identCluster(a.Ident) != identCluster(b.Ident) {
+			return identCluster(a.Ident) < identCluster(b.Ident)
+		}
+		if tiers[a.Ident] != tiers[b.Ident] {
+			return tiers[a.Ident] < tiers[b.Ident]
+		}
+		if o.identOrder[a.Ident] != o.identOrder[b.Ident] {
+			return o.identOrder[a.Ident] < o.identOrder[b.Ident]
+		}
+		if a.NamespacedName.Namespace != b.NamespacedName.Namespace {
+			return a.NamespacedName.Namespace < b.NamespacedName.Namespace
+		}
+		return a.NamespacedName.Name < b.NamespacedName.Name
+	})
 
-	err := o.applyResourceCache(dataToSort)
-	if err != nil {
+	return dataToSort
+}
+
+func (o *ObjectCache) applyResourceCache(ctx context.Context, cachedData objectsToApply) error {
+	byCluster := make(map[string][]ObjectToApply)
+	var clusters []string
+	for _, v := range cachedData.objs {
+		cluster := identCluster(v.Ident)
+		if _, ok := byCluster[cluster]; !ok {
+			clusters = append(clusters, cluster)
+		}
+		byCluster[cluster] = append(byCluster[cluster], v)
+	}
+	sort.Strings(clusters)
+
+	var errs []error
+	for _, cluster := range clusters {
+		result := o.lastApply.cluster(cluster)
+		if err := o.applyCluster(ctx, cluster, byCluster[cluster], result); err != nil {
+			result.Err = err
+			errs = append(errs, clusterError(cluster, err))
+		}
+	}
+	return joinErrors(errs)
+}
+
+// applyCluster applies the objects of a single cluster in order, stopping at the first error or when
+// the context is done. Objects left behind are recorded as unapplied. In transactional mode the
+// writes already made are then undone.
+func (o *ObjectCache) applyCluster(ctx context.Context, cluster string, objs []ObjectToApply, result *ClusterResult) error {
+	journal, err := o.applyObjects(ctx, cluster, objs, result)
+	notReady := &NotReadyError{}
+	if err == nil || !o.config.options.Transactional || errors.As(err, &notReady) {
 		return err
 	}
 
-	return nil
+	result.Rollback = o.rollback(ctx, cluster, journal)
+	if result.Rollback.Err != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", result.Rollback.Err))
+	}
+	return err
 }
 
-func (o *ObjectCache) applyResourceCache(cachedData objectsToApply) error {
-	for _, v := range cachedData.objs {
+// applyObjects writes the objects in order, returning a journal of the writes made when the cache is
+// transactional. Before moving on to the next tier of the ordering, it waits for the readiness gates
+// of the tier just written.
+func (o *ObjectCache) applyObjects(ctx context.Context, cluster string, objs []ObjectToApply, result *ClusterResult) ([]journalEntry, error) {
+	transactional := o.config.options.Transactional
+	tracker := o.config.options.ChangeTracker
+	var journal []journalEntry
+
+	tierStart, tier := 0, 0
+	for i, v := range objs {
+		gvk, _ := o.gvkFor(v.Ident.GetType())
+		if t := o.config.ordering.tier(gvk); i == 0 || t != tier {
+			if i > 0 {
+				if err := o.waitReady(ctx, cluster, objs[tierStart:i]); err != nil {
+					o.recordUnapplied(objs[i:], result)
+					return journal, err
+				}
+			}
+			tierStart, tier = i, t
+		}
+
 		if v.Ident.GetWriteNow() {
 			continue
 		}
-		if o.config.options.DebugOptions.Apply {
-			jsonData, _ := json.MarshalIndent(v.Resource.Object, "", "  ")
-			diff := difflib.UnifiedDiff{
-				A:        difflib.SplitLines(string(jsonData)),
-				B:        difflib.SplitLines(v.Resource.jsonData),
-				FromFile: "old",
-				ToFile:   "new",
-				Context:  3,
+		if err := ctx.Err(); err != nil {
+			o.recordUnapplied(objs[i:], result)
+			return journal, fmt.Errorf("apply stopped with %d objects unapplied: %w", len(result.Unapplied), err)
+		}
+		ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object)
+		if err != nil {
+			o.recordUnapplied(objs[i:], result)
+			return journal, err
+		}
+
+		if reason, paused := o.paused(v.Resource.origObject); paused {
+			o.log.Info("PAUSED resource ", "namespace", ref.NamespacedName.Namespace, "name", ref.NamespacedName.Name, "kind", ref.GVK.Kind, "cluster", ref.Cluster, "reason", reason)
+			result.Paused = append(result.Paused, ref)
+			continue
+		}
+
+		var hash string
+		if tracker != nil {
+			if hash, err = o.desiredHash(v.Resource); err != nil {
+				o.recordUnapplied(objs[i:], result)
+				return journal, err
 			}
-			text, _ := difflib.GetUnifiedDiffString(diff)
-			if v.Resource.Object.GetObjectKind().GroupVersionKind() == secretCompare {
-				o.log.Info("Update diff", "diff", "hidden", "type", "update", "resType", v.Resource.Object.GetObjectKind().GroupVersionKind().Kind, "name", v.NamespacedName.Name, "namespace", v.NamespacedName.Namespace)
-			} else {
-				o.log.Info("Update diff", "diff", text, "type", "update", "resType", v.Resource.Object.GetObjectKind().GroupVersionKind().Kind, "name", v.NamespacedName.Name, "namespace", v.NamespacedName.Namespace)
+			if bool(v.Resource.Update) && tracker.unchanged(ref, hash, v.Resource.origObject.GetResourceVersion()) {
+				o.log.V(1).Info("APPLY resource (unchanged since last apply)", "namespace", ref.NamespacedName.Namespace, "name", ref.NamespacedName.Name, "kind", ref.GVK.Kind, "cluster", ref.Cluster)
+				result.Skipped = append(result.Skipped, ref)
+				continue
 			}
 		}
 
-		if !equality.Semantic.DeepEqual(v.Resource.origObject, v.Resource.Object) || !bool(v.Resource.Update) {
-			o.log.Info("APPLY resource ", "namespace", v.NamespacedName.Namespace, "name", v.NamespacedName.Name, "provider", v.Ident.GetProvider(), "purpose", v.Ident.GetPurpose(), "kind", v.Resource.Object.GetObjectKind().GroupVersionKind().Kind, "update", v.Resource.Update, "skipped", false)
-			if err := v.Resource.Update.Apply(o.ctx, o.client, v.Resource.Object); err != nil {
-				return err
+		var entry journalEntry
+		if transactional {
+			if entry, err = newJournalEntry(ref, v.Ident, v.Resource); err != nil {
+				o.recordUnapplied(objs[i:], result)
+				return journal, err
+			}
+		}
+
+		var diff ObjectDiff
+		if o.config.options.History != nil {
+			if diff, err = o.diff(ref, v.Resource); err != nil {
+				o.recordUnapplied(objs[i:], result)
+				return journal, err
+			}
+		}
+
+		applied, err := o.applyObject(ctx, v.Ident, v.NamespacedName, v.Resource, "APPLY")
+		recreateErr := &recreateError{}
+		deleted := errors.As(err, &recreateErr)
+		if transactional && (applied || deleted || (err == nil && entry.statusWritten)) {
+			entry.applied = v.Resource.Object.DeepCopyObject().(client.Object)
+			entry.mainWritten = applied
+			entry.statusWritten = entry.statusWritten && err == nil
+			entry.recreated = deleted || (!entry.created && entry.applied.GetUID() != entry.orig.GetUID())
+			journal = append(journal, entry)
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, ref)
+			o.recordUnapplied(objs[i+1:], result)
+			return journal, err
+		}
+		if tracker != nil {
+			resourceVersion := v.Resource.origObject.GetResourceVersion()
+			if applied {
+				resourceVersion = v.Resource.Object.GetResourceVersion()
+			}
+			tracker.recordApplied(ref, hash, resourceVersion)
+		}
+		if applied {
+			result.Applied = append(result.Applied, ref)
+			if o.config.options.History != nil {
+				result.Diffs = append(result.Diffs, diff)
 			}
 		} else {
-			o.log.Info("APPLY resource (skipped)", "namespace", v.NamespacedName.Namespace, "name", v.NamespacedName.Name, "provider", v.Ident.GetProvider(), "purpose", v.Ident.GetPurpose(), "kind", v.Resource.Object.GetObjectKind().GroupVersionKind().Kind, "update", v.Resource.Update, "skipped", true)
+			result.Skipped = append(result.Skipped, ref)
 		}
+	}
+	return journal, nil
+}
 
-		if v.Resource.Status {
-			if err := o.client.Status().Update(o.ctx, v.Resource.Object); err != nil {
-				return err
+// recordUnapplied adds the objects that ApplyAll would have written to the result's unapplied list.
+func (o *ObjectCache) recordUnapplied(objs []ObjectToApply, result *ClusterResult) {
+	for _, v := range objs {
+		if v.Ident.GetWriteNow() {
+			continue
+		}
+		if ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object); err == nil {
+			result.Unapplied = append(result.Unapplied, ref)
+		}
+	}
+}
+
+// applyObject writes a single cached resource to the ident's cluster, skipping the write if the
+// object existed and has not been modified since it was fetched. It reports whether anything was
+// written. The verb is only used to label the log lines.
+func (o *ObjectCache) applyObject(ctx context.Context, ident ResourceIdent, nn types.NamespacedName, res *k8sResource, verb string) (bool, error) {
+	kclient, err := o.clientFor(identCluster(ident))
+	if err != nil {
+		return false, err
+	}
+
+	kind := res.Object.GetObjectKind().GroupVersionKind().Kind
+
+	if o.config.options.DebugOptions.Apply {
+		jsonData, _ := json.MarshalIndent(res.Object, "", "  ")
+		diff := difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(jsonData)),
+			B:        difflib.SplitLines(res.jsonData),
+			FromFile: "old",
+			ToFile:   "new",
+			Context:  3,
+		}
+		text, _ := difflib.GetUnifiedDiffString(diff)
+		if res.Object.GetObjectKind().GroupVersionKind() == secretCompare {
+			o.log.Info("Update diff", "diff", "hidden", "type", "update", "resType", kind, "name", nn.Name, "namespace", nn.Namespace)
+		} else {
+			o.log.Info("Update diff", "diff", text, "type", "update", "resType", kind, "name", nn.Name, "namespace", nn.Namespace)
+		}
+	}
+
+	apply, err := needsApply(res)
+	if err != nil {
+		return false, err
+	}
+
+	var desired client.Object
+	if res.Status {
+		desired = res.Object.DeepCopyObject().(client.Object)
+	}
+
+	if apply {
+		o.log.Info(verb+" resource ", "namespace", nn.Namespace, "name", nn.Name, "provider", ident.GetProvider(), "purpose", ident.GetPurpose(), "kind", kind, "cluster", identCluster(ident), "update", res.Update, "skipped", false)
+
+		var fields []string
+		if res.Update {
+			if fields, err = o.immutableFieldsChanged(res); err != nil {
+				return false, err
 			}
 		}
+
+		if len(fields) > 0 {
+			if err := o.handleImmutable(ctx, kclient, ident, nn, res, fields, nil); err != nil {
+				return false, err
+			}
+		} else if err := o.throttle(ctx, writeVerb(res.Update)); err != nil {
+			return false, err
+		} else if err := res.Update.Apply(ctx, kclient, res.Object); err != nil {
+			fields = immutableFieldsFromError(err)
+			if len(fields) == 0 {
+				return false, err
+			}
+			if err := o.handleImmutable(ctx, kclient, ident, nn, res, fields, err); err != nil {
+				return false, err
+			}
+		}
+	} else {
+		o.log.Info(verb+" resource (skipped)", "namespace", nn.Namespace, "name", nn.Name, "provider", ident.GetProvider(), "purpose", ident.GetPurpose(), "kind", kind, "cluster", identCluster(ident), "update", res.Update, "skipped", true)
 	}
-	return nil
+
+	if res.Status {
+		live := res.Object
+		if !apply {
+			live = res.origObject
+		}
+		if err := o.applyStatus(ctx, kclient, res, live, desired); err != nil {
+			return apply, err
+		}
+	}
+
+	return apply, nil
 }
 
-// Debug prints out the contents of the cache.
+// Debug prints out the contents of the cache. DebugLog and DebugJSON give a structured description
+// that includes the objects themselves.
 func (o *ObjectCache) Debug() {
 	for iden, v := range o.data {
 		fmt.Printf("\n%v-%v", iden.GetProvider(), iden.GetPurpose())
@@ -605,8 +1038,11 @@ func (o *ObjectCache) Debug() {
 			if err != nil {
 				fmt.Print(err.Error())
 			}
-			gvks, _, _ := o.scheme.ObjectKinds(i.Object)
-			gvk := gvks[0]
+			gvk, err := o.gvkFor(i.Object)
+			if err != nil {
+				fmt.Printf("\nObject %v - %v - %v - %v\n", nn, i.Update, err, pi)
+				continue
+			}
 			fmt.Printf("\nObject %v - %v - %v - %v\n", nn, i.Update, gvk, pi)
 		}
 	}
@@ -614,19 +1050,112 @@ func (o *ObjectCache) Debug() {
 
 func (o *ObjectCache) AddPossibleGVKFromIdent(objs ...ResourceIdent) {
 	for _, obj := range objs {
-		gvk, _ := utils.GetKindFromObj(o.scheme, obj.GetType())
+		gvk, _ := o.gvkFor(obj.GetType())
 		o.config.possibleGVKs[gvk] = true
 	}
 }
 
-// Reconcile performs the delete on objects that are no longer required
+// Reconcile performs the delete on objects that are no longer required. The local cluster is always
+// reconciled; remote clusters are reconciled when Options.OwnershipLabel is set. An error stops the
+// cluster it happened in, but not the others; LastReconcileResult reports the outcome per cluster.
 func (o *ObjectCache) Reconcile(ownedUID types.UID, opts ...client.ListOption) error {
+	return o.ReconcileContext(o.ctx, ownedUID, opts...)
+}
+
+// ReconcileContext is Reconcile using the given context, bounded by Timeouts.Reconcile if set. When
+// the context is done, Reconcile stops before the next delete and returns the context's error.
+func (o *ObjectCache) ReconcileContext(ctx context.Context, ownedUID types.UID, opts ...client.ListOption) error {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Reconcile)
+	defer cancel()
+
+	start := time.Now()
+	o.lastReconcile = newApplyResult()
+
+	var errs []error
+	for _, cluster := range o.reconciledClusters() {
+		result := o.lastReconcile.cluster(cluster)
+		if err := o.reconcileCluster(ctx, cluster, result, ownedUID, opts...); err != nil {
+			result.Err = err
+			errs = append(errs, clusterError(cluster, err))
+		}
+	}
+	err := joinErrors(errs)
+	o.record("reconcile", ownedUID, start, o.lastReconcile, err)
+	return err
+}
+
+// reconciledClusters returns the clusters Reconcile and Plan look for orphans in.
+func (o *ObjectCache) reconciledClusters() []string {
+	if o.config.options.OwnershipLabel == "" {
+		return []string{LocalCluster}
+	}
+	return o.clusterNames()
+}
+
+func (o *ObjectCache) reconcileCluster(ctx context.Context, cluster string, result *ClusterResult, ownedUID types.UID, opts ...client.ListOption) error {
+	kclient, err := o.clientFor(cluster)
+	if err != nil {
+		return err
+	}
+
+	// Objects that are owned but left alone stay tracked, so they are considered again.
+	kept := o.cachedRefs(cluster)
+	orphans, incremental, err := o.trackedOrphans(ctx, kclient, cluster, ownedUID, kept, opts...)
+	if err != nil {
+		return err
+	}
+	if !incremental {
+		if orphans, err = o.orphans(ctx, kclient, cluster, ownedUID, opts...); err != nil {
+			return err
+		}
+	}
+
+	for i := range orphans {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		obj := &orphans[i]
+		ref := ObjectRef{
+			Cluster:        cluster,
+			GVK:            obj.GroupVersionKind(),
+			NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()},
+		}
+		if reason, paused := o.paused(obj); paused {
+			o.log.Info("PAUSED resource ", "namespace", obj.GetNamespace(), "name", obj.GetName(), "kind", ref.GVK.Kind, "cluster", cluster, "reason", reason)
+			result.Paused = append(result.Paused, ref)
+			kept[ref] = true
+			continue
+		}
+		o.log.Info("DELETE resource ", "namespace", obj.GetNamespace(), "name", obj.GetName(), "kind", obj.GetObjectKind().GroupVersionKind().Kind, "cluster", cluster)
+		if err := o.throttle(ctx, "delete"); err != nil {
+			return err
+		}
+		if err := kclient.Delete(ctx, obj); err != nil {
+			result.Failed = append(result.Failed, ref)
+			return err
+		}
+		result.Deleted = append(result.Deleted, ref)
+		if tracker := o.config.options.ChangeTracker; tracker != nil {
+			tracker.recordDeleted(ref)
+		}
+	}
+
+	if tracker := o.config.options.ChangeTracker; tracker != nil {
+		tracker.recordTracked(ownedUID, cluster, kept, !incremental)
+	}
+	return nil
+}
+
+// orphans lists every object of a possible, non protected, GVK in the cluster that is owned by
+// ownedUID but is not in the cache.
+func (o *ObjectCache) orphans(ctx context.Context, kclient client.Client, cluster string, ownedUID types.UID, opts ...client.ListOption) ([]unstructured.Unstructured, error) {
+	var orphans []unstructured.Unstructured
 
 	for gvk := range o.config.possibleGVKs {
 		if _, ok := o.config.protectedGVKs[gvk]; ok {
 			continue
 		}
-		v, ok := o.resourceTracker[gvk]
+		v, ok := o.resourceTracker[trackerKey{Cluster: cluster, GVK: gvk}]
 
 		if !ok {
 			v = make(map[types.NamespacedName]bool)
@@ -635,38 +1164,37 @@ func (o *ObjectCache) Reconcile(ownedUID types.UID, opts ...client.ListOption) e
 		nobjList := unstructured.UnstructuredList{}
 		nobjList.SetGroupVersionKind(gvk)
 
-		err := o.client.List(o.ctx, &nobjList, opts...)
+		err := kclient.List(ctx, &nobjList, opts...)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// fmt.Printf("\n%v %v", gvk, len(nobjList.Items))
-
 		for _, obj := range nobjList.Items {
-			innerObj := obj
-			for _, ownerRef := range innerObj.GetOwnerReferences() {
-				if ownerRef.UID == ownedUID {
-					nn := types.NamespacedName{
-						Name:      innerObj.GetName(),
-						Namespace: innerObj.GetNamespace(),
-					}
-					if err != nil {
-						return err
-					}
-					// fmt.Printf("\n%v\n", v)
-					if _, ok := v[nn]; !ok {
-						o.log.Info("DELETE resource ", "namespace", innerObj.GetNamespace(), "name", innerObj.GetName(), "kind", innerObj.GetObjectKind().GroupVersionKind().Kind)
-						err := o.client.Delete(o.ctx, &innerObj)
-						if err != nil {
-							return err
-						}
-					}
-				}
+			if !o.owned(&obj, ownedUID) {
+				continue
+			}
+			nn := types.NamespacedName{
+				Name:      obj.GetName(),
+				Namespace: obj.GetNamespace(),
+			}
+			if _, ok := v[nn]; !ok {
+				orphans = append(orphans, obj)
 			}
 		}
 	}
-	// fmt.Println("\n-----------------")
-	return nil
+	return orphans, nil
+}
+
+// owned reports whether the object has an owner reference to ownedUID, or carries the ownership
+// label with ownedUID as its value.
+func (o *ObjectCache) owned(obj client.Object, ownedUID types.UID) bool {
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.UID == ownedUID {
+			return true
+		}
+	}
+	label := o.config.options.OwnershipLabel
+	return label != "" && obj.GetLabels()[label] == string(ownedUID)
 }
 
 func getNamespacedNameFromRuntime(object client.Object) (types.NamespacedName, error) {
@@ -1,28 +1,43 @@
 package resourcecache
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
-	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/RedHatInsights/rhc-osdk-utils/resources"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap"
 	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
 	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	ctrlzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -272,6 +287,56 @@ func createRandomServices(n int) []identAndObject {
 	return listOfObjects
 }
 
+// basicIdent implements only the methods required by ResourceIdent, as idents written before the
+// optional ClusterIdent and ImmutablePolicyIdent interfaces do.
+type basicIdent struct {
+	provider string
+	purpose  string
+	typ      client.Object
+}
+
+func (b basicIdent) GetProvider() string    { return b.provider }
+func (b basicIdent) GetPurpose() string     { return b.purpose }
+func (b basicIdent) GetType() client.Object { return b.typ }
+func (b basicIdent) GetWriteNow() bool      { return false }
+
+func TestObjectCacheBasicIdent(t *testing.T) {
+	ctx := context.Background()
+
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	nn := types.NamespacedName{
+		Name:      "test-basic-ident",
+		Namespace: "default",
+	}
+
+	ident := basicIdent{provider: "TEST", purpose: "BASIC", typ: &core.ConfigMap{}}
+	assert.Equal(t, LocalCluster, identCluster(ident))
+	assert.Equal(t, ImmutablePolicy{}, identImmutablePolicy(ident))
+
+	cm := &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+	}
+	err := oCache.Create(ident, nn, cm)
+	assert.NoError(t, err)
+
+	cm.Data = map[string]string{"key": "value"}
+	err = oCache.Update(ident, cm)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	live := &core.ConfigMap{}
+	err = k8sClient.Get(ctx, nn, live)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", live.Data["key"])
+}
+
 func TestObjectCacheOrdering(t *testing.T) {
 
 	config := NewCacheConfig(scheme, nil, nil)
@@ -451,18 +516,7 @@ func TestOrderingSort(t *testing.T) {
 		assert.Nil(t, err, "error from create call inside 250 loop")
 	}
 
-	dataToSort := objectsToApply{scheme: oCache.scheme, order: applyOrder}
-	for res := range oCache.data {
-		for nn := range oCache.data[res] {
-			dataToSort.objs = append(dataToSort.objs, ObjectToApply{
-				Ident:          res,
-				NamespacedName: nn,
-				Resource:       oCache.data[res][nn],
-			})
-		}
-	}
-
-	sort.Sort(dataToSort)
+	dataToSort := oCache.sortedObjects()
 	gvk, err := utils.GetKindFromObj(oCache.scheme, dataToSort.objs[len(dataToSort.objs)-1].Ident.GetType())
 	assert.NoError(t, err)
 	assert.Equal(t, "Deployment", gvk.Kind)
@@ -471,6 +525,71 @@ func TestOrderingSort(t *testing.T) {
 	assert.Equal(t, "Secret", gvk.Kind)
 }
 
+func TestOrderingPatterns(t *testing.T) {
+	order := newOrdering([]string{"*", "Deployment", "apps/*/Deployment", "/v1/ConfigMap", "batch/Job", "keda.sh/*/*"})
+	assert.NoError(t, order.err)
+
+	appsDeployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	crdDeployment := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Deployment"}
+	assert.Equal(t, 2, order.tier(appsDeployment))
+	assert.Equal(t, 1, order.tier(crdDeployment))
+	assert.Equal(t, 3, order.tier(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+	assert.Equal(t, 0, order.tier(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "ConfigMap"}))
+	assert.Equal(t, 4, order.tier(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}))
+	assert.Equal(t, 5, order.tier(schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "ScaledObject"}))
+
+	// Without "*", unmatched kinds come first.
+	order = newOrdering([]string{"core/v1/Service"})
+	assert.Equal(t, -1, order.tier(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}))
+	assert.Equal(t, 0, order.tier(schema.GroupVersionKind{Version: "v1", Kind: "Service"}))
+
+	order = newOrdering([]string{"*", "a/b/c/Deployment", "apps/v1/"})
+	assert.ErrorContains(t, order.err, "invalid ordering pattern [a/b/c/Deployment]")
+	assert.ErrorContains(t, order.err, "invalid ordering pattern [apps/v1/]")
+	assert.Equal(t, 0, order.tier(appsDeployment))
+
+	ctx := context.Background()
+	oCache := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil, Options{Ordering: []string{"*", "apps/v1/"}}))
+	err := oCache.ApplyAll()
+	assert.ErrorContains(t, err, "invalid ordering pattern")
+}
+
+func TestOrderingInsertionOrder(t *testing.T) {
+	ctx := context.Background()
+	oCache := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil))
+
+	SecretIdent := NewMultiResourceIdent("TEST", "ORDER-SECRET", &core.Secret{})
+	CMIdent := NewMultiResourceIdent("TEST", "ORDER-CM", &core.ConfigMap{})
+	create := func(ident ResourceIdent, obj client.Object, name string) {
+		err := oCache.Create(ident, types.NamespacedName{Name: name, Namespace: "default"}, obj)
+		assert.NoError(t, err)
+	}
+	create(SecretIdent, &core.Secret{}, "test-order-b")
+	create(CMIdent, &core.ConfigMap{}, "test-order-b")
+	create(SecretIdent, &core.Secret{}, "test-order-a")
+	create(CMIdent, &core.ConfigMap{}, "test-order-a")
+
+	var got []string
+	for _, v := range oCache.sortedObjects().objs {
+		got = append(got, v.Ident.GetPurpose()+" "+v.NamespacedName.Name)
+	}
+	assert.Equal(t, []string{
+		"ORDER-SECRET test-order-a",
+		"ORDER-SECRET test-order-b",
+		"ORDER-CM test-order-a",
+		"ORDER-CM test-order-b",
+	}, got)
+
+	// Idents created after a snapshot lose their place when it is rolled back.
+	token := oCache.Snapshot()
+	LateIdent := NewMultiResourceIdent("TEST", "ORDER-LATE", &core.ConfigMap{})
+	create(LateIdent, &core.ConfigMap{}, "test-order-late")
+	err := oCache.Rollback(token)
+	assert.NoError(t, err)
+	assert.NotContains(t, oCache.identOrder, LateIdent)
+	assert.Len(t, oCache.identOrder, 2)
+}
+
 func TestObjectCachePreseedStrictFail(t *testing.T) {
 
 	config := NewCacheConfig(scheme, nil, nil, Options{
@@ -829,3 +948,2067 @@ func TestCacheAddPossibleGVK(t *testing.T) {
 	assert.Nil(t, err, "get object was not nil")
 	assert.Contains(t, oCache.config.possibleGVKs, obj)
 }
+
+func TestObjectCacheImmutableFail(t *testing.T) {
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "test-immutable-fail",
+		Namespace: "default",
+	}
+
+	live := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+		Immutable: utils.TruePtr(),
+		Data:      map[string]string{"key": "old"},
+	}
+	err := k8sClient.Create(ctx, &live)
+	assert.NoError(t, err)
+
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	SingleIdent := NewSingleResourceIdent("TEST", "IMMUTABLE", &core.ConfigMap{})
+
+	cm := core.ConfigMap{}
+	err = oCache.Create(SingleIdent, nn, &cm)
+	assert.NoError(t, err)
+
+	cm.Data["key"] = "new"
+	err = oCache.Update(SingleIdent, &cm)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	var immutableErr *ImmutableFieldError
+	assert.ErrorAs(t, err, &immutableErr)
+	assert.Equal(t, []string{"data"}, immutableErr.Fields)
+
+	err = k8sClient.Get(ctx, nn, &cm)
+	assert.NoError(t, err)
+	assert.Equal(t, "old", cm.Data["key"])
+}
+
+func TestObjectCacheImmutableRecreate(t *testing.T) {
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "test-immutable-recreate",
+		Namespace: "default",
+	}
+
+	live := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+		Immutable: utils.TruePtr(),
+		Data:      map[string]string{"key": "old"},
+	}
+	err := k8sClient.Create(ctx, &live)
+	assert.NoError(t, err)
+
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	SingleIdent := NewSingleResourceIdent("TEST", "IMMUTABLE", &core.ConfigMap{}, ResourceOptions{
+		Immutable: ImmutablePolicy{
+			Action:      ImmutableRecreate,
+			Propagation: metav1.DeletePropagationForeground,
+		},
+	})
+
+	cm := core.ConfigMap{}
+	err = oCache.Create(SingleIdent, nn, &cm)
+	assert.NoError(t, err)
+
+	cm.Data["key"] = "new"
+	err = oCache.Update(SingleIdent, &cm)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	recreated := core.ConfigMap{}
+	err = k8sClient.Get(ctx, nn, &recreated)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", recreated.Data["key"])
+	assert.NotEqual(t, live.UID, recreated.UID)
+}
+
+func TestPrepareForRecreateUnstructuredJob(t *testing.T) {
+	job := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":            "test-recreate-job",
+			"namespace":       "default",
+			"uid":             "1234",
+			"resourceVersion": "5",
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"controller-uid": "1234"},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{
+						"app":                          "test",
+						"controller-uid":               "1234",
+						"job-name":                     "test-recreate-job",
+						"batch.kubernetes.io/job-name": "test-recreate-job",
+					},
+				},
+			},
+		},
+	}}
+
+	prepareForRecreate(job)
+
+	assert.Empty(t, job.GetUID())
+	assert.Empty(t, job.GetResourceVersion())
+	_, found, _ := unstructured.NestedFieldNoCopy(job.Object, "spec", "selector")
+	assert.False(t, found)
+	labels, _, _ := unstructured.NestedStringMap(job.Object, "spec", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"app": "test"}, labels)
+
+	manual := job.DeepCopy()
+	_ = unstructured.SetNestedField(manual.Object, true, "spec", "manualSelector")
+	_ = unstructured.SetNestedField(manual.Object, "1234", "spec", "selector", "matchLabels", "controller-uid")
+	prepareForRecreate(manual)
+	_, found, _ = unstructured.NestedFieldNoCopy(manual.Object, "spec", "selector")
+	assert.True(t, found)
+}
+
+func TestImmutableFieldsIgnoreServerDefaults(t *testing.T) {
+	ctx := context.Background()
+
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	template := func(defaulted bool) core.PodTemplateSpec {
+		spec := core.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test"}},
+			Spec: core.PodSpec{
+				RestartPolicy: core.RestartPolicyNever,
+				Containers:    []core.Container{{Name: "main", Image: "busybox"}},
+			},
+		}
+		if defaulted {
+			spec.Spec.DNSPolicy = core.DNSClusterFirst
+			spec.Spec.SchedulerName = "default-scheduler"
+			spec.Spec.TerminationGracePeriodSeconds = utils.Int64Ptr(30)
+			spec.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+			spec.Spec.Containers[0].ImagePullPolicy = core.PullAlways
+		}
+		return spec
+	}
+
+	liveJob := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-defaults", Namespace: "default"},
+		Spec:       batch.JobSpec{Template: template(true)},
+	}
+	desiredJob := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-defaults", Namespace: "default"},
+		Spec:       batch.JobSpec{Template: template(false)},
+	}
+
+	fields, err := oCache.immutableFieldsChanged(&k8sResource{Object: desiredJob, origObject: liveJob})
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	desiredJob.Spec.Template.Spec.Containers[0].Image = "alpine"
+	fields, err = oCache.immutableFieldsChanged(&k8sResource{Object: desiredJob, origObject: liveJob})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spec.template"}, fields)
+
+	liveSecret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-defaults", Namespace: "default"},
+		Type:       core.SecretTypeOpaque,
+	}
+	desiredSecret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-defaults", Namespace: "default"},
+	}
+
+	fields, err = oCache.immutableFieldsChanged(&k8sResource{Object: desiredSecret, origObject: liveSecret})
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	desiredSecret.Type = core.SecretTypeTLS
+	fields, err = oCache.immutableFieldsChanged(&k8sResource{Object: desiredSecret, origObject: liveSecret})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"type"}, fields)
+}
+
+func TestObjectCacheStatusOnly(t *testing.T) {
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "test-status-only",
+		Namespace: "default",
+	}
+
+	live := apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nn.Name,
+			Namespace: nn.Namespace,
+		},
+		Spec: apps.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"test": "test"},
+			},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"test": "test"},
+				},
+				Spec: core.PodSpec{
+					Containers: []core.Container{{
+						Name:  "test",
+						Image: "test",
+					}},
+				},
+			},
+		},
+	}
+	err := k8sClient.Create(ctx, &live)
+	assert.NoError(t, err)
+
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	SingleIdent := NewSingleResourceIdent("TEST", "STATUS", &apps.Deployment{})
+
+	d := apps.Deployment{}
+	err = oCache.Create(SingleIdent, nn, &d)
+	assert.NoError(t, err)
+
+	d.Status.ObservedGeneration = d.Generation
+	d.Status.Conditions = []apps.DeploymentCondition{{
+		Type:   apps.DeploymentProgressing,
+		Status: core.ConditionTrue,
+		Reason: "Testing",
+	}}
+	err = oCache.UpdateStatus(SingleIdent, &d)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	applied := apps.Deployment{}
+	err = k8sClient.Get(ctx, nn, &applied)
+	assert.NoError(t, err)
+	assert.Equal(t, live.Generation, applied.Status.ObservedGeneration)
+	assert.Len(t, applied.Status.Conditions, 1)
+	assert.Equal(t, "Testing", applied.Status.Conditions[0].Reason)
+}
+
+var manifestBundle = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-manifest-existing
+  namespace: default
+data:
+  key: from-manifest
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test-manifest-new
+  namespace: default
+  labels:
+    app: manifest
+data:
+  key: new
+`
+
+func TestObjectCacheLoadManifests(t *testing.T) {
+	ctx := context.Background()
+
+	existing := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-manifest-existing",
+			Namespace: "default",
+			Labels:    map[string]string{"live": "label"},
+		},
+		Data: map[string]string{"key": "live", "other": "live"},
+	}
+	err := k8sClient.Create(ctx, &existing)
+	assert.NoError(t, err)
+
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "MANIFESTS", &core.ConfigMap{})
+
+	err = oCache.LoadManifests(MultiIdent, strings.NewReader(manifestBundle))
+	assert.NoError(t, err)
+
+	cmList := core.ConfigMapList{}
+	err = oCache.List(MultiIdent, &cmList)
+	assert.NoError(t, err)
+	assert.Len(t, cmList.Items, 2)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	merged := core.ConfigMap{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-manifest-existing", Namespace: "default"}, &merged)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-manifest", merged.Data["key"])
+	assert.Equal(t, "live", merged.Data["other"])
+	assert.Equal(t, "label", merged.Labels["live"])
+
+	created := core.ConfigMap{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-manifest-new", Namespace: "default"}, &created)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", created.Data["key"])
+	assert.Equal(t, "manifest", created.Labels["app"])
+
+	SingleIdent := NewSingleResourceIdent("TEST", "MANIFEST-SINGLE", &core.ConfigMap{})
+	err = oCache.LoadManifests(SingleIdent, strings.NewReader(manifestBundle))
+	assert.ErrorContains(t, err, "cannot load 2 manifests into single ident")
+}
+
+func TestObjectCacheExport(t *testing.T) {
+	ctx := context.Background()
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	nn := types.NamespacedName{
+		Name:      "test-export",
+		Namespace: "default",
+	}
+
+	// Objects sharing a tier are exported in the order their idents were created.
+	SingleIdentCM := NewSingleResourceIdent("TEST", "EXPORT-CM", &core.ConfigMap{})
+	cm := core.ConfigMap{}
+	err := oCache.Create(SingleIdentCM, nn, &cm)
+	assert.NoError(t, err)
+	cm.Name, cm.Namespace = nn.Name, nn.Namespace
+	cm.Data = map[string]string{"key": "value"}
+	err = oCache.Update(SingleIdentCM, &cm)
+	assert.NoError(t, err)
+
+	SingleIdentSecret := NewSingleResourceIdent("TEST", "EXPORT-SECRET", &core.Secret{})
+	secret := core.Secret{}
+	err = oCache.Create(SingleIdentSecret, nn, &secret)
+	assert.NoError(t, err)
+	secret.Name, secret.Namespace = nn.Name, nn.Namespace
+	secret.Data = map[string][]byte{"password": []byte("hunter2")}
+	err = oCache.Update(SingleIdentSecret, &secret)
+	assert.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	err = oCache.Export(&buf, ExportYAML, ExportOptions{StripServerFields: true, RedactSecrets: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `---
+apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  name: test-export
+  namespace: default
+---
+apiVersion: v1
+data:
+  password: UkVEQUNURUQ=
+kind: Secret
+metadata:
+  name: test-export
+  namespace: default
+`, buf.String())
+
+	// A redacted Secret can still be decoded and loaded.
+	reloaded := NewObjectCache(ctx, k8sClient, &log, config)
+	ReloadIdent := NewSingleResourceIdent("TEST", "EXPORT-RELOAD", &core.Secret{})
+	docs := strings.Split(buf.String(), "---\n")
+	err = reloaded.LoadManifests(ReloadIdent, strings.NewReader(docs[len(docs)-1]))
+	assert.NoError(t, err)
+	reloadedSecret := core.Secret{}
+	err = reloaded.Get(ReloadIdent, &reloadedSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("REDACTED"), reloadedSecret.Data["password"])
+
+	buf.Reset()
+	err = oCache.Export(&buf, ExportJSON)
+	assert.NoError(t, err)
+
+	exported, err := decodeManifests(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, exported, 2)
+	assert.Equal(t, "Secret", exported[1].GetKind())
+	data, _, _ := unstructured.NestedString(exported[1].Object, "data", "password")
+	assert.Equal(t, "aHVudGVyMg==", data)
+
+	// The status is stripped along with the server populated metadata.
+	statusCache := NewObjectCache(ctx, k8sClient, &log, config)
+	DeploymentIdent := NewSingleResourceIdent("TEST", "EXPORT-DEPLOYMENT", &apps.Deployment{})
+	d := apps.Deployment{}
+	err = statusCache.Create(DeploymentIdent, nn, &d)
+	assert.NoError(t, err)
+	d.Name, d.Namespace = nn.Name, nn.Namespace
+	d.Status.Replicas = 3
+	err = statusCache.Update(DeploymentIdent, &d)
+	assert.NoError(t, err)
+
+	buf.Reset()
+	err = statusCache.Export(&buf, ExportYAML, ExportOptions{StripServerFields: true})
+	assert.NoError(t, err)
+	assert.NotContains(t, buf.String(), "status:")
+	assert.NotContains(t, buf.String(), "replicas: 3")
+}
+
+// countingReader counts the Gets made through it.
+type countingReader struct {
+	client.Reader
+	gets int
+}
+
+func (r *countingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	r.gets++
+	return r.Reader.Get(ctx, key, obj, opts...)
+}
+
+func TestObjectCachePrefetch(t *testing.T) {
+	ctx := context.Background()
+
+	existing := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-prefetch-existing",
+			Namespace: "default",
+		},
+		Data: map[string]string{"key": "live"},
+	}
+	err := k8sClient.Create(ctx, &existing)
+	assert.NoError(t, err)
+
+	reader := &countingReader{Reader: k8sClient}
+	config := NewCacheConfig(scheme, nil, nil, Options{Reader: reader})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "PREFETCH", &core.ConfigMap{})
+	oCache.AddPossibleGVKFromIdent(MultiIdent)
+
+	err = oCache.Prefetch("default")
+	assert.NoError(t, err)
+
+	cm := core.ConfigMap{}
+	err = oCache.Create(MultiIdent, types.NamespacedName{Name: "test-prefetch-existing", Namespace: "default"}, &cm)
+	assert.NoError(t, err)
+	assert.Equal(t, "live", cm.Data["key"])
+
+	missing := core.ConfigMap{}
+	err = oCache.Create(MultiIdent, types.NamespacedName{Name: "test-prefetch-missing", Namespace: "default"}, &missing)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, reader.gets)
+
+	other := core.ConfigMap{}
+	err = oCache.Create(MultiIdent, types.NamespacedName{Name: "test-prefetch-other", Namespace: "kafka"}, &other)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reader.gets)
+
+	cm.Data["key"] = "updated"
+	err = oCache.Update(MultiIdent, &cm)
+	assert.NoError(t, err)
+	missing.Name, missing.Namespace = "test-prefetch-missing", "default"
+	err = oCache.Update(MultiIdent, &missing)
+	assert.NoError(t, err)
+	other.Name, other.Namespace = "test-prefetch-other", "kafka"
+	err = oCache.Update(MultiIdent, &other)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	updated := core.ConfigMap{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-prefetch-existing", Namespace: "default"}, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", updated.Data["key"])
+
+	created := core.ConfigMap{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-prefetch-missing", Namespace: "default"}, &created)
+	assert.NoError(t, err)
+}
+
+func TestObjectCacheUnstructured(t *testing.T) {
+	ctx := context.Background()
+
+	cmGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(cmGVK, meta.RESTScopeNamespace)
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-unstructured-owner",
+			Namespace: "default",
+		},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	orphan := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-unstructured-orphan",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Name:       owner.Name,
+				UID:        owner.UID,
+			}},
+		},
+	}
+	err = k8sClient.Create(ctx, &orphan)
+	assert.NoError(t, err)
+
+	// The cache's scheme has no types registered, as is the case for third party CRDs.
+	config := NewCacheConfig(runtime.NewScheme(), nil, nil, Options{RESTMapper: mapper})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	MultiIdent := NewMultiUnstructuredResourceIdent("TEST", "UNSTRUCTURED", cmGVK)
+
+	nn := types.NamespacedName{
+		Name:      "test-unstructured",
+		Namespace: "default",
+	}
+
+	cm := &unstructured.Unstructured{}
+	err = oCache.Create(MultiIdent, nn, cm)
+	assert.NoError(t, err)
+	assert.Equal(t, cmGVK, cm.GroupVersionKind())
+
+	cm.SetName(nn.Name)
+	cm.SetNamespace(nn.Namespace)
+	cm.SetOwnerReferences(orphan.OwnerReferences)
+	err = unstructured.SetNestedField(cm.Object, "value", "data", "key")
+	assert.NoError(t, err)
+	err = oCache.Update(MultiIdent, cm)
+	assert.NoError(t, err)
+
+	got := &unstructured.Unstructured{}
+	err = oCache.Get(MultiIdent, got, nn)
+	assert.NoError(t, err)
+	value, _, _ := unstructured.NestedString(got.Object, "data", "key")
+	assert.Equal(t, "value", value)
+
+	err = unstructured.SetNestedField(got.Object, "changed", "data", "key")
+	assert.NoError(t, err)
+	list := unstructured.UnstructuredList{}
+	err = oCache.List(MultiIdent, &list)
+	assert.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+	value, _, _ = unstructured.NestedString(list.Items[0].Object, "data", "key")
+	assert.Equal(t, "value", value, "modifying an object returned by Get must not change the cache")
+
+	err = oCache.Create(MultiIdent, types.NamespacedName{Name: "test-unstructured-scope"}, &unstructured.Unstructured{})
+	assert.ErrorContains(t, err, "is namespaced but")
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	applied := core.ConfigMap{}
+	err = k8sClient.Get(ctx, nn, &applied)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", applied.Data["key"])
+
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: orphan.Name, Namespace: orphan.Namespace}, &core.ConfigMap{})
+	assert.True(t, k8serr.IsNotFound(err))
+	err = k8sClient.Get(ctx, nn, &core.ConfigMap{})
+	assert.NoError(t, err)
+}
+
+func TestObjectCacheClusters(t *testing.T) {
+	ctx := context.Background()
+
+	orphan := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-clusters-orphan",
+			Namespace: "default",
+			Labels:    map[string]string{"owner": "test-clusters-owner"},
+		},
+	}
+	remote := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(&orphan).Build()
+
+	config := NewCacheConfig(scheme, nil, nil, Options{
+		Clusters:       map[string]client.Client{"remote": remote},
+		OwnershipLabel: "owner",
+	})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	nn := types.NamespacedName{
+		Name:      "test-clusters",
+		Namespace: "default",
+	}
+
+	LocalIdent := NewSingleResourceIdent("TEST", "LOCAL", &core.ConfigMap{})
+	RemoteIdent := NewSingleResourceIdent("TEST", "REMOTE", &core.ConfigMap{}, ResourceOptions{Cluster: "remote"})
+	MissingIdent := NewSingleResourceIdent("TEST", "MISSING", &core.ConfigMap{}, ResourceOptions{Cluster: "missing"})
+
+	for _, ident := range []ResourceIdentSingle{LocalIdent, RemoteIdent} {
+		cm := core.ConfigMap{}
+		err := oCache.Create(ident, nn, &cm)
+		assert.NoError(t, err)
+		cm.Name, cm.Namespace = nn.Name, nn.Namespace
+		cm.Labels = map[string]string{"owner": "test-clusters-owner"}
+		cm.Data = map[string]string{"cluster": ident.GetPurpose()}
+		err = oCache.Update(ident, &cm)
+		assert.NoError(t, err)
+	}
+
+	err := oCache.Create(MissingIdent, nn, &core.ConfigMap{})
+	assert.ErrorContains(t, err, "cluster [missing] is not registered")
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	local := core.ConfigMap{}
+	err = k8sClient.Get(ctx, nn, &local)
+	assert.NoError(t, err)
+	assert.Equal(t, "LOCAL", local.Data["cluster"])
+
+	remoteCM := core.ConfigMap{}
+	err = remote.Get(ctx, nn, &remoteCM)
+	assert.NoError(t, err)
+	assert.Equal(t, "REMOTE", remoteCM.Data["cluster"])
+
+	result := oCache.LastApplyResult()
+	assert.Len(t, result.Clusters, 2)
+	assert.Len(t, result.Clusters[LocalCluster].Applied, 1)
+	assert.Len(t, result.Clusters["remote"].Applied, 1)
+	assert.Equal(t, "remote", result.Clusters["remote"].Applied[0].Cluster)
+
+	err = oCache.Reconcile("test-clusters-owner")
+	assert.NoError(t, err)
+
+	err = remote.Get(ctx, types.NamespacedName{Name: orphan.Name, Namespace: orphan.Namespace}, &core.ConfigMap{})
+	assert.True(t, k8serr.IsNotFound(err))
+	err = remote.Get(ctx, nn, &core.ConfigMap{})
+	assert.NoError(t, err)
+
+	reconciled := oCache.LastReconcileResult()
+	assert.Len(t, reconciled.Clusters["remote"].Deleted, 1)
+	assert.Empty(t, reconciled.Clusters[LocalCluster].Deleted)
+}
+
+func TestObjectCacheApplyAllCancelled(t *testing.T) {
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(context.Background(), k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "CANCELLED", &core.ConfigMap{})
+
+	for _, name := range []string{"test-cancelled-a", "test-cancelled-b"} {
+		nn := types.NamespacedName{Name: name, Namespace: "default"}
+		cm := core.ConfigMap{}
+		err := oCache.CreateContext(context.Background(), MultiIdent, nn, &cm)
+		assert.NoError(t, err)
+		cm.Name, cm.Namespace = nn.Name, nn.Namespace
+		err = oCache.UpdateContext(context.Background(), MultiIdent, &cm)
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := oCache.ApplyAllContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "2 objects unapplied")
+
+	result := oCache.LastApplyResult().Clusters[LocalCluster]
+	assert.Empty(t, result.Applied)
+	assert.Len(t, result.Unapplied, 2)
+	assert.Equal(t, "test-cancelled-a", result.Unapplied[0].NamespacedName.Name)
+
+	err = k8sClient.Get(context.Background(), types.NamespacedName{Name: "test-cancelled-a", Namespace: "default"}, &core.ConfigMap{})
+	assert.True(t, k8serr.IsNotFound(err))
+
+	err = oCache.ApplyAllContext(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, oCache.LastApplyResult().Clusters[LocalCluster].Applied, 2)
+}
+
+// countingLimiter is a write rate limiter that records every wait and delays each by a fixed time.
+// When failAt is set, that wait fails instead.
+type countingLimiter struct {
+	flowcontrol.RateLimiter
+	delay  time.Duration
+	waits  int
+	failAt int
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	if l.waits == l.failAt {
+		return fmt.Errorf("write %d refused", l.waits)
+	}
+	select {
+	case <-time.After(l.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestObjectCacheWriteRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	config := NewCacheConfig(scheme, nil, nil, Options{WriteQPS: 5, WriteBurst: 10})
+	assert.NotNil(t, config.options.WriteRateLimiter)
+
+	existing := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-ratelimit-existing",
+			Namespace: "default",
+		},
+	}
+	err := k8sClient.Create(ctx, &existing)
+	assert.NoError(t, err)
+
+	limiter := &countingLimiter{RateLimiter: flowcontrol.NewFakeAlwaysRateLimiter(), delay: 10 * time.Millisecond}
+	config = NewCacheConfig(scheme, nil, nil, Options{WriteRateLimiter: limiter})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "RATELIMIT", &core.ConfigMap{})
+	for _, name := range []string{"test-ratelimit-new", "test-ratelimit-existing"} {
+		nn := types.NamespacedName{Name: name, Namespace: "default"}
+		cm := core.ConfigMap{}
+		err := oCache.Create(MultiIdent, nn, &cm)
+		assert.NoError(t, err)
+		cm.Name, cm.Namespace = nn.Name, nn.Namespace
+		err = oCache.Update(MultiIdent, &cm)
+		assert.NoError(t, err)
+	}
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, limiter.waits, "only the created object should wait, the unchanged one is skipped")
+	assert.GreaterOrEqual(t, oCache.RateLimitWait(), 10*time.Millisecond)
+}
+
+func TestObjectCacheSnapshotRollback(t *testing.T) {
+	config := NewCacheConfig(scheme, nil, nil)
+	oCache := NewObjectCache(context.Background(), k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "SNAPSHOT", &core.ConfigMap{})
+
+	first := types.NamespacedName{Name: "test-snapshot-first", Namespace: "default"}
+	cm := core.ConfigMap{}
+	err := oCache.Create(MultiIdent, first, &cm)
+	assert.NoError(t, err)
+	cm.Name, cm.Namespace = first.Name, first.Namespace
+	cm.Data = map[string]string{"key": "before"}
+	err = oCache.Update(MultiIdent, &cm)
+	assert.NoError(t, err)
+
+	token := oCache.Snapshot()
+
+	cm.Data["key"] = "after"
+	err = oCache.Update(MultiIdent, &cm)
+	assert.NoError(t, err)
+
+	second := types.NamespacedName{Name: "test-snapshot-second", Namespace: "default"}
+	err = oCache.Create(MultiIdent, second, &core.ConfigMap{})
+	assert.NoError(t, err)
+	later := oCache.Snapshot()
+
+	err = oCache.Rollback(token)
+	assert.NoError(t, err)
+
+	got := core.ConfigMap{}
+	err = oCache.Get(MultiIdent, &got, first)
+	assert.NoError(t, err)
+	assert.Equal(t, "before", got.Data["key"])
+
+	err = oCache.Get(MultiIdent, &got, second)
+	assert.Error(t, err)
+	assert.False(t, oCache.resourceTracker[trackerKey{GVK: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}}][second])
+
+	// Changes made after the rollback must not leak into the snapshot.
+	got.Data["key"] = "changed"
+	err = oCache.Update(MultiIdent, &got)
+	assert.NoError(t, err)
+	err = oCache.Rollback(token)
+	assert.NoError(t, err)
+	err = oCache.Get(MultiIdent, &got, first)
+	assert.NoError(t, err)
+	assert.Equal(t, "before", got.Data["key"])
+
+	err = oCache.Rollback(later)
+	assert.ErrorContains(t, err, "unknown snapshot")
+}
+
+func TestObjectCacheTransactionalRollback(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"test-tx-b", "test-tx-c"} {
+		cm := core.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string]string{"key": "original"},
+		}
+		err := k8sClient.Create(ctx, &cm)
+		assert.NoError(t, err)
+	}
+
+	config := NewCacheConfig(scheme, nil, nil, Options{Transactional: true})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "TRANSACTIONAL", &core.ConfigMap{})
+	for _, name := range []string{"test-tx-a", "test-tx-b", "test-tx-c"} {
+		nn := types.NamespacedName{Name: name, Namespace: "default"}
+		cm := core.ConfigMap{}
+		err := oCache.Create(MultiIdent, nn, &cm)
+		assert.NoError(t, err)
+		cm.Name, cm.Namespace = nn.Name, nn.Namespace
+		cm.Data = map[string]string{"key": "applied"}
+		err = oCache.Update(MultiIdent, &cm)
+		assert.NoError(t, err)
+	}
+
+	// Changing test-tx-c behind the cache's back makes its update fail with a conflict.
+	stale := core.ConfigMap{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Name: "test-tx-c", Namespace: "default"}, &stale)
+	assert.NoError(t, err)
+	stale.Data["key"] = "elsewhere"
+	err = k8sClient.Update(ctx, &stale)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.True(t, k8serr.IsConflict(err))
+
+	result := oCache.LastApplyResult().Clusters[LocalCluster]
+	assert.Len(t, result.Applied, 2)
+	assert.Len(t, result.Failed, 1)
+	if assert.NotNil(t, result.Rollback) {
+		assert.NoError(t, result.Rollback.Err)
+		assert.Len(t, result.Rollback.Deleted, 1)
+		assert.Equal(t, "test-tx-a", result.Rollback.Deleted[0].NamespacedName.Name)
+		assert.Len(t, result.Rollback.Reverted, 1)
+		assert.Equal(t, "test-tx-b", result.Rollback.Reverted[0].NamespacedName.Name)
+	}
+
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-tx-a", Namespace: "default"}, &core.ConfigMap{})
+	assert.True(t, k8serr.IsNotFound(err))
+
+	reverted := core.ConfigMap{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "test-tx-b", Namespace: "default"}, &reverted)
+	assert.NoError(t, err)
+	assert.Equal(t, "original", reverted.Data["key"])
+}
+
+func TestObjectCacheTransactionalRollbackRecreate(t *testing.T) {
+	ctx := context.Background()
+
+	nn := types.NamespacedName{Name: "test-tx-recreate", Namespace: "default"}
+	live := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Immutable:  utils.TruePtr(),
+		Data:       map[string]string{"key": "original"},
+	}
+	err := k8sClient.Create(ctx, &live)
+	assert.NoError(t, err)
+
+	// The first write is the delete of the recreate, the second its create, which is refused.
+	limiter := &countingLimiter{RateLimiter: flowcontrol.NewFakeAlwaysRateLimiter(), failAt: 2}
+	config := NewCacheConfig(scheme, nil, nil, Options{Transactional: true, WriteRateLimiter: limiter})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	SingleIdent := NewSingleResourceIdent("TEST", "TX-RECREATE", &core.ConfigMap{}, ResourceOptions{
+		Immutable: ImmutablePolicy{Action: ImmutableRecreate},
+	})
+	cm := core.ConfigMap{}
+	err = oCache.Create(SingleIdent, nn, &cm)
+	assert.NoError(t, err)
+	cm.Data["key"] = "applied"
+	err = oCache.Update(SingleIdent, &cm)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.ErrorContains(t, err, "write 2 refused")
+
+	result := oCache.LastApplyResult().Clusters[LocalCluster]
+	assert.Len(t, result.Failed, 1)
+	if assert.NotNil(t, result.Rollback) {
+		assert.NoError(t, result.Rollback.Err)
+		assert.Len(t, result.Rollback.Reverted, 1)
+	}
+
+	restored := core.ConfigMap{}
+	err = k8sClient.Get(ctx, nn, &restored)
+	assert.NoError(t, err)
+	assert.Equal(t, "original", restored.Data["key"])
+}
+
+func TestObjectCacheValidate(t *testing.T) {
+	ctx := context.Background()
+
+	cmGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	validators := map[schema.GroupVersionKind][]Validator{
+		cmGVK: {func(obj client.Object) field.ErrorList {
+			if obj.GetLabels()["team"] == "" {
+				return field.ErrorList{field.Required(field.NewPath("metadata", "labels").Key("team"), "")}
+			}
+			return nil
+		}},
+	}
+	config := NewCacheConfig(scheme, nil, nil, Options{Validators: validators})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	MultiIdent := NewMultiResourceIdent("TEST", "VALIDATE", &core.ConfigMap{})
+
+	valid := types.NamespacedName{Name: "test-validate-valid", Namespace: "default"}
+	cm := core.ConfigMap{}
+	err := oCache.Create(MultiIdent, valid, &cm)
+	assert.NoError(t, err)
+	cm.Name, cm.Namespace = valid.Name, valid.Namespace
+	cm.Labels = map[string]string{"team": "platform"}
+	err = oCache.Update(MultiIdent, &cm)
+	assert.NoError(t, err)
+
+	invalid := types.NamespacedName{Name: "test-validate-invalid", Namespace: "default"}
+	cm = core.ConfigMap{}
+	err = oCache.Create(MultiIdent, invalid, &cm)
+	assert.NoError(t, err)
+	cm.Name, cm.Namespace = invalid.Name, invalid.Namespace
+	cm.Annotations = map[string]string{"not a valid/key/": "value"}
+	err = oCache.Update(MultiIdent, &cm)
+	assert.NoError(t, err)
+
+	err = oCache.Validate()
+	validationErr := &ValidationError{}
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Len(t, validationErr.Objects, 1)
+		assert.Equal(t, invalid, validationErr.Objects[0].Ref.NamespacedName)
+		// Both the metadata check and the registered validator report a problem.
+		assert.Len(t, validationErr.Objects[0].Errors, 2)
+	}
+
+	err = oCache.ApplyAll()
+	assert.ErrorAs(t, err, &validationErr)
+
+	// Nothing is written when validation fails, not even the valid object.
+	err = k8sClient.Get(ctx, valid, &core.ConfigMap{})
+	assert.True(t, k8serr.IsNotFound(err))
+}
+
+func TestValidatePodTemplateLabels(t *testing.T) {
+	d := &apps.Deployment{}
+	d.Spec.Template.Labels = map[string]string{"app": "web", "bad key": "value", "tier": strings.Repeat("x", 64)}
+	d.Spec.Template.Spec.Containers = []core.Container{{Name: "web", Image: "web:latest"}}
+
+	errs := validateDeployment(d)
+	if assert.Len(t, errs, 2) {
+		for _, err := range errs {
+			assert.Equal(t, "spec.template.metadata.labels", err.Field)
+		}
+	}
+}
+
+func TestObjectCacheOwner(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-owner", Namespace: "default"},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	config := NewCacheConfig(scheme, nil, nil, Options{Owner: &owner, OwnershipLabel: DefaultOwnershipLabel})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	CMIdent := NewSingleResourceIdent("TEST", "OWNER", &core.ConfigMap{})
+	NSIdent := NewSingleResourceIdent("TEST", "OWNER_NS", &core.Namespace{})
+
+	cmNN := types.NamespacedName{Name: "test-owner-owned", Namespace: "default"}
+	cm := core.ConfigMap{}
+	err = oCache.Create(CMIdent, cmNN, &cm)
+	assert.NoError(t, err)
+	cm.Name, cm.Namespace = cmNN.Name, cmNN.Namespace
+	err = oCache.Update(CMIdent, &cm)
+	assert.NoError(t, err)
+
+	nsNN := types.NamespacedName{Name: "test-owner-ns"}
+	ns := core.Namespace{}
+	err = oCache.Create(NSIdent, nsNN, &ns)
+	assert.NoError(t, err)
+	ns.Name = nsNN.Name
+	err = oCache.Update(NSIdent, &ns)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+
+	owned := core.ConfigMap{}
+	err = k8sClient.Get(ctx, cmNN, &owned)
+	assert.NoError(t, err)
+	if assert.Len(t, owned.OwnerReferences, 1) {
+		assert.Equal(t, owner.UID, owned.OwnerReferences[0].UID)
+		assert.Equal(t, "ConfigMap", owned.OwnerReferences[0].Kind)
+		assert.True(t, *owned.OwnerReferences[0].Controller)
+	}
+
+	// A cluster scoped object cannot reference a namespaced owner, so it is labelled instead.
+	labelled := core.Namespace{}
+	err = k8sClient.Get(ctx, nsNN, &labelled)
+	assert.NoError(t, err)
+	assert.Empty(t, labelled.OwnerReferences)
+	assert.Equal(t, string(owner.UID), labelled.Labels[DefaultOwnershipLabel])
+
+	// An object controlled by something else is not taken over.
+	oCache = NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil, Options{Owner: &labelled}))
+	err = oCache.Create(CMIdent, cmNN, &cm)
+	assert.NoError(t, err)
+	_, err = oCache.Plan(owner.UID)
+	assert.ErrorContains(t, err, "already controlled by ConfigMap [test-owner]")
+}
+
+func TestObjectCacheOwnerDefaultLabel(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-owner-default-label", Namespace: "default"},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	// An object carrying the default label, but no owner reference, is owned.
+	labelledNN := types.NamespacedName{Name: "test-owner-default-label-labelled", Namespace: "default"}
+	err = k8sClient.Create(ctx, &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      labelledNN.Name,
+			Namespace: labelledNN.Namespace,
+			Labels:    map[string]string{DefaultOwnershipLabel: string(owner.UID)},
+		},
+	})
+	assert.NoError(t, err)
+
+	possibleGVKs := GVKMap{
+		{Version: "v1", Kind: "ConfigMap"}: true,
+		{Version: "v1", Kind: "Namespace"}: true,
+	}
+
+	// The label defaults without remote clusters.
+	config := NewCacheConfig(scheme, possibleGVKs, nil, Options{Owner: &owner})
+	assert.Equal(t, DefaultOwnershipLabel, config.options.OwnershipLabel)
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	// A cluster scoped object cannot reference the owner and is labelled.
+	NSIdent := NewSingleResourceIdent("TEST", "OWNER_DEFAULT_LABEL_NS", &core.Namespace{})
+	nsNN := types.NamespacedName{Name: "test-owner-default-label-ns"}
+	ns := core.Namespace{}
+	err = oCache.Create(NSIdent, nsNN, &ns)
+	assert.NoError(t, err)
+	ns.Name = nsNN.Name
+	err = oCache.Update(NSIdent, &ns)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+
+	labelled := core.Namespace{}
+	err = k8sClient.Get(ctx, nsNN, &labelled)
+	assert.NoError(t, err)
+	assert.Empty(t, labelled.OwnerReferences)
+	assert.Equal(t, string(owner.UID), labelled.Labels[DefaultOwnershipLabel])
+
+	err = k8sClient.Get(ctx, labelledNN, &core.ConfigMap{})
+	assert.True(t, k8serr.IsNotFound(err))
+
+	// Once the namespace is no longer cached, Reconcile deletes it through the label.
+	oCache = NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, possibleGVKs, nil, Options{Owner: &owner}))
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+
+	deleted := core.Namespace{}
+	err = k8sClient.Get(ctx, nsNN, &deleted)
+	if err == nil {
+		// envtest runs no namespace controller, so the namespace stays terminating.
+		assert.NotNil(t, deleted.DeletionTimestamp)
+	} else {
+		assert.True(t, k8serr.IsNotFound(err))
+	}
+}
+
+func TestObjectCacheAdoption(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-adoption-owner", Namespace: "default"},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	plainNN := types.NamespacedName{Name: "test-adoption-plain", Namespace: "default"}
+	labelledNN := types.NamespacedName{Name: "test-adoption-labelled", Namespace: "default"}
+	err = k8sClient.Create(ctx, &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: plainNN.Name, Namespace: plainNN.Namespace},
+	})
+	assert.NoError(t, err)
+	err = k8sClient.Create(ctx, &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      labelledNN.Name,
+			Namespace: labelledNN.Namespace,
+			Labels:    map[string]string{DefaultOwnershipLabel: string(owner.UID)},
+		},
+	})
+	assert.NoError(t, err)
+
+	CMIdent := NewMultiResourceIdent("TEST", "ADOPTION", &core.ConfigMap{})
+
+	newCache := func(policy AdoptionPolicy, nns ...types.NamespacedName) ObjectCache {
+		config := NewCacheConfig(scheme, nil, nil, Options{Owner: &owner, OwnershipLabel: DefaultOwnershipLabel, AdoptionPolicy: policy})
+		oCache := NewObjectCache(ctx, k8sClient, &log, config)
+		for _, nn := range nns {
+			cm := core.ConfigMap{}
+			err := oCache.Create(CMIdent, nn, &cm)
+			assert.NoError(t, err)
+			cm.Data = map[string]string{"adopted": "yes"}
+			err = oCache.Update(CMIdent, &cm)
+			assert.NoError(t, err)
+		}
+		return oCache
+	}
+
+	// Nothing is written when the policy refuses any object.
+	oCache := newCache(AdoptNever, plainNN, labelledNN)
+	err = oCache.ApplyAll()
+	adoptionErr := &AdoptionError{}
+	if assert.ErrorAs(t, err, &adoptionErr) {
+		assert.Equal(t, AdoptNever, adoptionErr.Policy)
+		assert.Len(t, adoptionErr.Objects, 2)
+	}
+	plain := core.ConfigMap{}
+	err = k8sClient.Get(ctx, plainNN, &plain)
+	assert.NoError(t, err)
+	assert.Empty(t, plain.OwnerReferences)
+	assert.Empty(t, plain.Data)
+
+	oCache = newCache(AdoptIfLabelled, plainNN, labelledNN)
+	err = oCache.ApplyAll()
+	if assert.ErrorAs(t, err, &adoptionErr) && assert.Len(t, adoptionErr.Objects, 1) {
+		assert.Equal(t, plainNN, adoptionErr.Objects[0].NamespacedName)
+	}
+
+	oCache = newCache(AdoptIfLabelled, labelledNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	adopted := oCache.LastApplyResult().Clusters[LocalCluster].Adopted
+	if assert.Len(t, adopted, 1) {
+		assert.Equal(t, labelledNN, adopted[0].NamespacedName)
+	}
+	labelled := core.ConfigMap{}
+	err = k8sClient.Get(ctx, labelledNN, &labelled)
+	assert.NoError(t, err)
+	if assert.Len(t, labelled.OwnerReferences, 1) {
+		assert.Equal(t, owner.UID, labelled.OwnerReferences[0].UID)
+	}
+
+	oCache = newCache(AdoptAlways, plainNN, labelledNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	adopted = oCache.LastApplyResult().Clusters[LocalCluster].Adopted
+	if assert.Len(t, adopted, 1) {
+		assert.Equal(t, plainNN, adopted[0].NamespacedName)
+	}
+	err = k8sClient.Get(ctx, plainNN, &plain)
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", plain.Data["adopted"])
+	if assert.Len(t, plain.OwnerReferences, 1) {
+		assert.Equal(t, owner.UID, plain.OwnerReferences[0].UID)
+	}
+
+	// Objects already owned are not adopted again.
+	oCache = newCache(AdoptNever, plainNN, labelledNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	assert.Empty(t, oCache.LastApplyResult().Clusters[LocalCluster].Adopted)
+}
+
+func TestObjectCacheTeardown(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-teardown-owner",
+			Namespace:  "default",
+			Finalizers: []string{"test.example.com/teardown"},
+		},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+	ownerRef := utils.MakeOwnerReference(&owner)
+	ownerRef.APIVersion, ownerRef.Kind = "v1", "ConfigMap"
+
+	dep := apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-teardown-deployment",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{ownerRef},
+		},
+		Spec: apps.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "teardown"}},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"test": "teardown"}},
+				Spec: core.PodSpec{
+					Containers: []core.Container{{Name: "test", Image: "test"}},
+				},
+			},
+		},
+	}
+	err = k8sClient.Create(ctx, &dep)
+	assert.NoError(t, err)
+
+	// The finalizer holds the secret in deletion until the test removes it.
+	secret := core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-teardown-secret",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{ownerRef},
+			Finalizers:      []string{"test.example.com/hold"},
+		},
+	}
+	err = k8sClient.Create(ctx, &secret)
+	assert.NoError(t, err)
+
+	possibleGVKs := GVKMap{
+		{Group: "apps", Version: "v1", Kind: "Deployment"}: true,
+		{Version: "v1", Kind: "Secret"}:                    true,
+	}
+	oCache := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, possibleGVKs, nil))
+
+	// Deployments come after "*" in the default ordering, so they are torn down first.
+	result, err := oCache.Teardown(&owner, "test.example.com/teardown")
+	assert.NoError(t, err)
+	assert.False(t, result.Done)
+	assert.Equal(t, 1, result.Tier)
+	if assert.Len(t, result.Deleted, 1) {
+		assert.Equal(t, "test-teardown-deployment", result.Deleted[0].NamespacedName.Name)
+	}
+
+	// There is no garbage collector to finish the foreground deletion, so do it by hand.
+	depNN := types.NamespacedName{Name: dep.Name, Namespace: dep.Namespace}
+	if err := k8sClient.Get(ctx, depNN, &dep); err == nil {
+		dep.Finalizers = nil
+		err = k8sClient.Update(ctx, &dep)
+		assert.NoError(t, err)
+	}
+
+	result, err = oCache.Teardown(&owner, "test.example.com/teardown")
+	assert.NoError(t, err)
+	assert.False(t, result.Done)
+	assert.Equal(t, 0, result.Tier)
+	assert.Len(t, result.Deleted, 1)
+	assert.Len(t, result.Remaining, 1)
+
+	result, err = oCache.Teardown(&owner, "test.example.com/teardown")
+	assert.NoError(t, err)
+	assert.False(t, result.Done)
+	assert.Empty(t, result.Deleted)
+	assert.Len(t, result.Remaining, 1)
+
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, &secret)
+	assert.NoError(t, err)
+	secret.Finalizers = nil
+	err = k8sClient.Update(ctx, &secret)
+	assert.NoError(t, err)
+
+	// The owner changed since it was read; the finalizer is still removed without a conflict.
+	ownerNN := types.NamespacedName{Name: owner.Name, Namespace: owner.Namespace}
+	fresh := core.ConfigMap{}
+	err = k8sClient.Get(ctx, ownerNN, &fresh)
+	assert.NoError(t, err)
+	fresh.Data = map[string]string{"changed": "true"}
+	err = k8sClient.Update(ctx, &fresh)
+	assert.NoError(t, err)
+
+	result, err = oCache.Teardown(&owner, "test.example.com/teardown")
+	assert.NoError(t, err)
+	assert.True(t, result.Done)
+
+	err = k8sClient.Get(ctx, ownerNN, &owner)
+	assert.NoError(t, err)
+	assert.Empty(t, owner.Finalizers)
+	assert.Equal(t, "true", owner.Data["changed"])
+}
+
+func TestObjectCacheTeardownNoGVKs(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-teardown-nogvks-owner",
+			Namespace:  "default",
+			Finalizers: []string{"test.example.com/teardown"},
+		},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	// A deletion reconcile whose config lists no GVKs cannot tell whether anything is owned.
+	oCache := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil))
+	result, err := oCache.Teardown(&owner, "test.example.com/teardown")
+	assert.ErrorContains(t, err, "no possible GVKs")
+	assert.False(t, result.Done)
+
+	ownerNN := types.NamespacedName{Name: owner.Name, Namespace: owner.Namespace}
+	err = k8sClient.Get(ctx, ownerNN, &owner)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test.example.com/teardown"}, owner.Finalizers)
+
+	// With a ChangeTracker, the GVKs of the objects applied for the owner are searched too.
+	tracker := NewChangeTracker(0)
+	cmGVKs := GVKMap{{Version: "v1", Kind: "ConfigMap"}: true}
+	oCache = NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, cmGVKs, nil, Options{Owner: &owner, ChangeTracker: tracker}))
+	Ident := NewSingleResourceIdent("TEST", "TEARDOWN_NOGVKS", &core.ConfigMap{})
+	nn := types.NamespacedName{Name: "test-teardown-nogvks-owned", Namespace: "default"}
+	cm := core.ConfigMap{}
+	err = oCache.Create(Ident, nn, &cm)
+	assert.NoError(t, err)
+	cm.Name, cm.Namespace = nn.Name, nn.Namespace
+	err = oCache.Update(Ident, &cm)
+	assert.NoError(t, err)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+
+	oCache = NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil, Options{ChangeTracker: tracker}))
+	result, err = oCache.Teardown(&owner, "test.example.com/teardown")
+	assert.NoError(t, err)
+	assert.False(t, result.Done)
+	if assert.Len(t, result.Deleted, 1) {
+		assert.Equal(t, nn, result.Deleted[0].NamespacedName)
+	}
+}
+
+func TestObjectCacheFind(t *testing.T) {
+	oCache := NewObjectCache(context.Background(), k8sClient, &log, NewCacheConfig(scheme, nil, nil))
+
+	objs := []struct {
+		ident ResourceIdent
+		nn    types.NamespacedName
+		obj   client.Object
+	}{
+		{NewSingleResourceIdent("FIRST", "SERVICE", &core.Service{}), types.NamespacedName{Name: "test-find-first", Namespace: "default"}, &core.Service{}},
+		{NewSingleResourceIdent("SECOND", "SERVICE", &core.Service{}), types.NamespacedName{Name: "test-find-second", Namespace: "other"}, &core.Service{}},
+		{NewSingleResourceIdent("FIRST", "CONFIG", &core.ConfigMap{}), types.NamespacedName{Name: "test-find-config", Namespace: "default"}, &core.ConfigMap{}},
+	}
+	for _, o := range objs {
+		err := oCache.Create(o.ident, o.nn, o.obj)
+		assert.NoError(t, err)
+		o.obj.SetName(o.nn.Name)
+		o.obj.SetNamespace(o.nn.Namespace)
+		o.obj.SetLabels(map[string]string{"provider": o.ident.GetProvider()})
+		err = oCache.Update(o.ident, o.obj)
+		assert.NoError(t, err)
+	}
+
+	entries, err := oCache.Find(Filter{GVK: schema.GroupVersionKind{Version: "v1", Kind: "Service"}})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = oCache.Find(Filter{Provider: "FIRST"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = oCache.Find(Filter{Namespace: "other"})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "test-find-second", entries[0].NamespacedName.Name)
+	}
+
+	selector, err := labels.Parse("provider=FIRST")
+	assert.NoError(t, err)
+	entries, err = oCache.Find(Filter{Purpose: "SERVICE", LabelSelector: selector})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		entry := entries[0]
+		assert.Equal(t, "test-find-first", entry.NamespacedName.Name)
+		assert.Equal(t, "Service", entry.GVK.Kind)
+		assert.False(t, entry.Update)
+		assert.True(t, entry.Dirty)
+		assert.False(t, entry.Status)
+
+		// The entry holds a copy, so the cache only changes when it is updated.
+		entry.Object.SetLabels(map[string]string{"provider": "changed"})
+		entries, err = oCache.Find(Filter{LabelSelector: selector})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+	}
+}
+
+func TestObjectCacheDebugJSON(t *testing.T) {
+	oCache := NewObjectCache(context.Background(), k8sClient, &log, NewCacheConfig(scheme, nil, nil))
+
+	SecretIdent := NewSingleResourceIdent("TEST", "DEBUG_SECRET", &core.Secret{})
+	nn := types.NamespacedName{Name: "test-debug-secret", Namespace: "default"}
+	secret := core.Secret{}
+	err := oCache.Create(SecretIdent, nn, &secret)
+	assert.NoError(t, err)
+	secret.Name, secret.Namespace = nn.Name, nn.Namespace
+	secret.Data = map[string][]byte{"password": []byte("hunter2")}
+	err = oCache.Update(SecretIdent, &secret)
+	assert.NoError(t, err)
+
+	cmGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	UnstructuredIdent := NewSingleUnstructuredResourceIdent("TEST", "DEBUG_UNSTRUCTURED", cmGVK)
+	cm := &unstructured.Unstructured{}
+	err = oCache.Create(UnstructuredIdent, types.NamespacedName{Name: "test-debug-cm", Namespace: "default"}, cm)
+	assert.NoError(t, err)
+
+	// Debug used to panic on objects whose kinds the scheme could not report.
+	oCache.Debug()
+	oCache.DebugLog(1)
+
+	data, err := oCache.DebugJSON()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	assert.NotContains(t, string(data), "aHVudGVyMg==")
+
+	dump := struct {
+		Objects []DebugEntry `json:"objects"`
+	}{}
+	err = json.Unmarshal(data, &dump)
+	assert.NoError(t, err)
+	if assert.Len(t, dump.Objects, 2) {
+		for _, entry := range dump.Objects {
+			assert.Equal(t, "TEST", entry.Provider)
+			assert.True(t, entry.Dirty)
+			assert.Empty(t, entry.Error)
+		}
+	}
+}
+
+func TestObjectCacheReadinessGates(t *testing.T) {
+	ctx := context.Background()
+
+	cmNN := types.NamespacedName{Name: "test-ready-config", Namespace: "default"}
+	depNN := types.NamespacedName{Name: "test-ready-deployment", Namespace: "default"}
+	gates := []ReadinessGate{{
+		GVK:  "core/v1/ConfigMap",
+		Name: cmNN.Name,
+		Ready: func(obj *unstructured.Unstructured) (bool, error) {
+			ready, _, err := unstructured.NestedString(obj.Object, "data", "ready")
+			return ready == "true", err
+		},
+	}}
+
+	CMIdent := NewSingleResourceIdent("TEST", "READY-CM", &core.ConfigMap{})
+	DepIdent := NewSingleResourceIdent("TEST", "READY-DEP", &apps.Deployment{})
+	newCache := func(timeout time.Duration) ObjectCache {
+		config := NewCacheConfig(scheme, nil, nil, Options{
+			ReadinessGates:   gates,
+			ReadinessBackoff: wait.Backoff{Duration: 10 * time.Millisecond, Factor: 2, Steps: 10, Cap: 50 * time.Millisecond},
+			Timeouts:         Timeouts{Readiness: timeout},
+		})
+		oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+		cm := core.ConfigMap{}
+		err := oCache.Create(CMIdent, cmNN, &cm)
+		assert.NoError(t, err)
+		cm.Name, cm.Namespace = cmNN.Name, cmNN.Namespace
+		err = oCache.Update(CMIdent, &cm)
+		assert.NoError(t, err)
+
+		dep := apps.Deployment{}
+		err = oCache.Create(DepIdent, depNN, &dep)
+		assert.NoError(t, err)
+		dep.Name, dep.Namespace = depNN.Name, depNN.Namespace
+		dep.Spec = apps.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "ready"}},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"test": "ready"}},
+				Spec: core.PodSpec{
+					Containers: []core.Container{{Name: "test", Image: "test"}},
+				},
+			},
+		}
+		err = oCache.Update(DepIdent, &dep)
+		assert.NoError(t, err)
+		return oCache
+	}
+
+	// Without a timeout the gate is checked once and a requeue hint returned.
+	oCache := newCache(0)
+	err := oCache.ApplyAll()
+	notReady := &NotReadyError{}
+	if assert.ErrorAs(t, err, &notReady) {
+		assert.Equal(t, LocalCluster, notReady.Cluster)
+		assert.Greater(t, notReady.RequeueAfter, time.Duration(0))
+		if assert.Len(t, notReady.Objects, 1) {
+			assert.Equal(t, cmNN, notReady.Objects[0].NamespacedName)
+		}
+	}
+	result := oCache.LastApplyResult().Clusters[LocalCluster]
+	assert.Len(t, result.Applied, 1)
+	if assert.Len(t, result.Unapplied, 1) {
+		assert.Equal(t, depNN, result.Unapplied[0].NamespacedName)
+	}
+	err = k8sClient.Get(ctx, depNN, &apps.Deployment{})
+	assert.True(t, k8serr.IsNotFound(err))
+
+	// With a timeout, ApplyAll waits for the ConfigMap to become ready.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cm := core.ConfigMap{}
+		if err := k8sClient.Get(ctx, cmNN, &cm); err == nil {
+			cm.Data = map[string]string{"ready": "true"}
+			_ = k8sClient.Update(ctx, &cm)
+		}
+	}()
+	oCache = newCache(5 * time.Second)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	err = k8sClient.Get(ctx, depNN, &apps.Deployment{})
+	assert.NoError(t, err)
+}
+
+func TestReadyFuncs(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"generation": int64(2)},
+		"status": map[string]interface{}{
+			"observedGeneration": int64(1),
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			},
+		},
+	}}
+
+	ready := ConditionsReady(resources.ResourceConditionReadyRequirements{Type: "Ready", Status: "True"})
+	ok, err := ready(obj)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = GenerationObserved()(obj)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	err = unstructured.SetNestedField(obj.Object, int64(2), "status", "observedGeneration")
+	assert.NoError(t, err)
+	ok, err = ready(obj)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = GenerationObserved()(obj)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ConditionsReady(resources.ResourceConditionReadyRequirements{Type: "Available", Status: "True"})(obj)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestGenerateName(t *testing.T) {
+	name, err := GenerateName("", NameKey{Owner: "app", Index: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "app-2", name)
+
+	name, err = GenerateName("", NameKey{Owner: "app", Index: 2, Suffix: "worker"})
+	assert.NoError(t, err)
+	assert.Equal(t, "app-worker-2", name)
+
+	long := NameKey{Owner: strings.Repeat("a", 60), Index: 1, Suffix: "worker"}
+	name, err = GenerateName("{{.Owner}}-{{.Suffix}}-{{.Index}}", long)
+	assert.NoError(t, err)
+	assert.Len(t, name, MaxGeneratedNameLength)
+	assert.True(t, strings.HasPrefix(name, strings.Repeat("a", 54)+"-"))
+	again, err := GenerateName("{{.Owner}}-{{.Suffix}}-{{.Index}}", long)
+	assert.NoError(t, err)
+	assert.Equal(t, name, again)
+	long.Index = 2
+	other, err := GenerateName("{{.Owner}}-{{.Suffix}}-{{.Index}}", long)
+	assert.NoError(t, err)
+	assert.NotEqual(t, name, other)
+
+	_, err = GenerateName("{{.Missing}}", NameKey{})
+	assert.ErrorContains(t, err, "cannot generate name")
+	_, err = GenerateName("{{.Owner", NameKey{})
+	assert.ErrorContains(t, err, "invalid name template")
+}
+
+func TestObjectCacheNameFor(t *testing.T) {
+	ctx := context.Background()
+	oCache := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil))
+
+	WorkerIdent := NewMultiResourceIdent("TEST", "WORKERS", &core.ConfigMap{}, ResourceOptions{NameTemplate: "{{.Owner}}-worker-{{.Index}}"})
+	for i := 0; i < 3; i++ {
+		nn, err := oCache.NameFor(WorkerIdent, "default", NameKey{Owner: "test-names", Index: i})
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("test-names-worker-%d", i), nn.Name)
+
+		cm := core.ConfigMap{}
+		err = oCache.Create(WorkerIdent, nn, &cm)
+		assert.NoError(t, err)
+		cm.Name, cm.Namespace = nn.Name, nn.Namespace
+		cm.Data = map[string]string{"index": strconv.Itoa(i)}
+		err = oCache.Update(WorkerIdent, &cm)
+		assert.NoError(t, err)
+	}
+
+	// Another provider finds an object by its logical key.
+	nn, ok := oCache.Lookup(WorkerIdent, NameKey{Owner: "test-names", Index: 1})
+	assert.True(t, ok)
+	assert.Equal(t, types.NamespacedName{Name: "test-names-worker-1", Namespace: "default"}, nn)
+	cm := core.ConfigMap{}
+	err := oCache.GetByKey(WorkerIdent, NameKey{Owner: "test-names", Index: 1}, &cm)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", cm.Data["index"])
+
+	_, ok = oCache.Lookup(WorkerIdent, NameKey{Owner: "test-names", Index: 7})
+	assert.False(t, ok)
+	err = oCache.GetByKey(WorkerIdent, NameKey{Owner: "test-names", Index: 7}, &cm)
+	assert.ErrorContains(t, err, "no name recorded")
+
+	// Keys that differ only in fields the template ignores collide.
+	_, err = oCache.NameFor(WorkerIdent, "default", NameKey{Owner: "test-names", Index: 1, Suffix: "ignored"})
+	assert.ErrorContains(t, err, "already used by key")
+
+	// Names must be valid for the kind.
+	_, err = oCache.NameFor(WorkerIdent, "default", NameKey{Owner: "Test_Names", Index: 1})
+	assert.ErrorContains(t, err, "is invalid")
+}
+
+func TestObjectCacheChangeTracker(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-tracker-owner", Namespace: "default"},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	tracker := NewChangeTracker(0)
+	CMIdent := NewMultiResourceIdent("TEST", "TRACKER", &core.ConfigMap{})
+	aNN := types.NamespacedName{Name: "test-tracker-a", Namespace: "default"}
+	bNN := types.NamespacedName{Name: "test-tracker-b", Namespace: "default"}
+
+	newCache := func(nns ...types.NamespacedName) ObjectCache {
+		config := NewCacheConfig(scheme, GVKMap{schema.GroupVersionKind{Kind: "ConfigMap", Version: "v1"}: true}, nil, Options{
+			Owner:         &owner,
+			ChangeTracker: tracker,
+		})
+		oCache := NewObjectCache(ctx, k8sClient, &log, config)
+		for _, nn := range nns {
+			cm := core.ConfigMap{}
+			err := oCache.Create(CMIdent, nn, &cm)
+			assert.NoError(t, err)
+			cm.Name, cm.Namespace = nn.Name, nn.Namespace
+			cm.Data = map[string]string{"value": "desired"}
+			err = oCache.Update(CMIdent, &cm)
+			assert.NoError(t, err)
+		}
+		return oCache
+	}
+
+	oCache := newCache(aNN, bNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	assert.Len(t, oCache.LastApplyResult().Clusters[LocalCluster].Applied, 2)
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+
+	// Nothing has changed, so nothing is written.
+	oCache = newCache(aNN, bNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	assert.Empty(t, oCache.LastApplyResult().Clusters[LocalCluster].Applied)
+	assert.Len(t, oCache.LastApplyResult().Clusters[LocalCluster].Skipped, 2)
+
+	// A live edit changes the resourceVersion, so the object is diffed and written again.
+	live := core.ConfigMap{}
+	err = k8sClient.Get(ctx, aNN, &live)
+	assert.NoError(t, err)
+	live.Data["value"] = "edited"
+	err = k8sClient.Update(ctx, &live)
+	assert.NoError(t, err)
+
+	oCache = newCache(aNN, bNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	applied := oCache.LastApplyResult().Clusters[LocalCluster].Applied
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, aNN, applied[0].NamespacedName)
+	}
+	err = k8sClient.Get(ctx, aNN, &live)
+	assert.NoError(t, err)
+	assert.Equal(t, "desired", live.Data["value"])
+
+	// An owned object the tracker has never seen is not found by an incremental Reconcile.
+	stray := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-tracker-stray",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Name:       owner.Name,
+				UID:        owner.UID,
+			}},
+		},
+	}
+	err = k8sClient.Create(ctx, &stray)
+	assert.NoError(t, err)
+
+	oCache = newCache(aNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+	deleted := oCache.LastReconcileResult().Clusters[LocalCluster].Deleted
+	if assert.Len(t, deleted, 1) {
+		assert.Equal(t, bNN, deleted[0].NamespacedName)
+	}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: stray.Name, Namespace: stray.Namespace}, &live)
+	assert.NoError(t, err)
+
+	// After Forget, Reconcile lists again.
+	tracker.Forget(owner.UID)
+	oCache = newCache(aNN)
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+	deleted = oCache.LastReconcileResult().Clusters[LocalCluster].Deleted
+	if assert.Len(t, deleted, 1) {
+		assert.Equal(t, stray.Name, deleted[0].NamespacedName.Name)
+	}
+}
+
+func TestChangeTrackerExpiry(t *testing.T) {
+	tracker := NewChangeTracker(time.Hour)
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	ref := ObjectRef{GVK: gvk, NamespacedName: types.NamespacedName{Name: "test-expiry", Namespace: "default"}}
+	other := ObjectRef{GVK: gvk, NamespacedName: types.NamespacedName{Name: "test-expiry-other", Namespace: "default"}}
+	owner := types.UID("expiry-owner")
+
+	tracker.recordApplied(ref, "hash", "1")
+	tracker.recordTracked(owner, LocalCluster, map[ObjectRef]bool{ref: true}, true)
+	assert.True(t, tracker.unchanged(ref, "hash", "1"))
+	_, ok := tracker.tracked(owner, LocalCluster)
+	assert.True(t, ok)
+
+	// Entries unused for the resync interval are dropped when read.
+	stale := time.Now().Add(-2 * time.Hour)
+	state := tracker.applied[ref]
+	state.seen = stale
+	tracker.applied[ref] = state
+	tracker.owners[owner][LocalCluster].seen = stale
+	assert.False(t, tracker.unchanged(ref, "hash", "1"))
+	assert.NotContains(t, tracker.applied, ref)
+	_, ok = tracker.tracked(owner, LocalCluster)
+	assert.False(t, ok)
+	assert.NotContains(t, tracker.owners, owner)
+
+	// And by the sweep, for entries never read again.
+	tracker.recordApplied(ref, "hash", "1")
+	tracker.recordTracked(owner, LocalCluster, map[ObjectRef]bool{ref: true}, true)
+	state = tracker.applied[ref]
+	state.seen = stale
+	tracker.applied[ref] = state
+	tracker.owners[owner][LocalCluster].seen = stale
+	tracker.swept = stale
+	tracker.recordApplied(other, "hash", "1")
+	assert.NotContains(t, tracker.applied, ref)
+	assert.Contains(t, tracker.applied, other)
+	assert.NotContains(t, tracker.owners, owner)
+}
+
+func TestObjectCacheHistory(t *testing.T) {
+	ctx := context.Background()
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-history-owner", Namespace: "default"},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+
+	history := NewHistory(2)
+	config := NewCacheConfig(scheme, nil, nil, Options{Owner: &owner, History: history})
+	oCache := NewObjectCache(ctx, k8sClient, &log, config)
+
+	SecretIdent := NewSingleResourceIdent("TEST", "HISTORY", &core.Secret{})
+	nn := types.NamespacedName{Name: "test-history-secret", Namespace: "default"}
+	secret := core.Secret{}
+	err = oCache.Create(SecretIdent, nn, &secret)
+	assert.NoError(t, err)
+	secret.Name, secret.Namespace = nn.Name, nn.Namespace
+	secret.Data = map[string][]byte{"password": []byte("hunter2")}
+	err = oCache.Update(SecretIdent, &secret)
+	assert.NoError(t, err)
+
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/cache?owner="+string(owner.UID), nil)
+	rec := httptest.NewRecorder()
+	history.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+	assert.NotContains(t, rec.Body.String(), "aHVudGVyMg==")
+
+	summaries := []Summary{}
+	err = json.Unmarshal(rec.Body.Bytes(), &summaries)
+	assert.NoError(t, err)
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, "apply", summaries[0].Operation)
+		assert.Equal(t, "reconcile", summaries[1].Operation)
+
+		local := summaries[0].Clusters[LocalCluster]
+		assert.Len(t, local.Applied, 1)
+		if assert.Len(t, local.Diffs, 1) {
+			assert.Contains(t, local.Diffs[0].Object, "test-history-secret")
+			assert.NotEmpty(t, local.Diffs[0].Patch)
+		}
+	}
+
+	// Only the most recent summaries are kept.
+	history.Add(Summary{Operation: "apply", Owner: owner.UID})
+	summaries = history.Summaries(owner.UID)
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, "reconcile", summaries[0].Operation)
+	}
+
+	rec = httptest.NewRecorder()
+	history.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/cache", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// Applies without an owner are not recorded.
+	unowned := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil, Options{History: history}))
+	err = unowned.ApplyAll()
+	assert.NoError(t, err)
+	assert.Empty(t, history.Summaries(""))
+
+	// Teardown forgets the owner once it is done.
+	owner.Finalizers = []string{"test.example.com/history"}
+	err = k8sClient.Update(ctx, &owner)
+	assert.NoError(t, err)
+	err = k8sClient.Delete(ctx, &core.Secret{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}})
+	assert.NoError(t, err)
+	oCache = NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, GVKMap{{Version: "v1", Kind: "Secret"}: true}, nil, Options{History: history}))
+	result, err := oCache.Teardown(&owner, "test.example.com/history")
+	assert.NoError(t, err)
+	assert.True(t, result.Done)
+	assert.Empty(t, history.Summaries(owner.UID))
+}
+
+func TestObjectCacheDetectDrift(t *testing.T) {
+	ctx := context.Background()
+
+	cmNN := types.NamespacedName{Name: "test-drift-cm", Namespace: "default"}
+	secretNN := types.NamespacedName{Name: "test-drift-secret", Namespace: "default"}
+	goneNN := types.NamespacedName{Name: "test-drift-gone", Namespace: "default"}
+	depNN := types.NamespacedName{Name: "test-drift-deployment", Namespace: "default"}
+	dep := apps.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: depNN.Name, Namespace: depNN.Namespace},
+		Spec: apps.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "drift"}},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"test": "drift"}},
+				Spec: core.PodSpec{
+					Containers: []core.Container{{Name: "test", Image: "test"}},
+				},
+			},
+		},
+	}
+	for _, obj := range []client.Object{
+		&core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cmNN.Name, Namespace: cmNN.Namespace}, Data: map[string]string{"key": "desired"}},
+		&core.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretNN.Name, Namespace: secretNN.Namespace}, Data: map[string][]byte{"password": []byte("hunter2")}},
+		&core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: goneNN.Name, Namespace: goneNN.Namespace}},
+		&dep,
+	} {
+		err := k8sClient.Create(ctx, obj)
+		assert.NoError(t, err)
+	}
+
+	// Edit the objects behind the operator's back, before the reconcile starts.
+	cm := core.ConfigMap{}
+	err := k8sClient.Get(ctx, cmNN, &cm)
+	assert.NoError(t, err)
+	cm.Data["key"] = "edited"
+	cm.Labels = map[string]string{"edited": "true"}
+	err = k8sClient.Update(ctx, &cm)
+	assert.NoError(t, err)
+
+	secret := core.Secret{}
+	err = k8sClient.Get(ctx, secretNN, &secret)
+	assert.NoError(t, err)
+	secret.Data["password"] = []byte("hunter3")
+	err = k8sClient.Update(ctx, &secret)
+	assert.NoError(t, err)
+
+	oCache := NewObjectCache(ctx, k8sClient, &log, NewCacheConfig(scheme, nil, nil))
+
+	// The providers build the desired state.
+	CMIdent := NewMultiResourceIdent("TEST", "DRIFT", &core.ConfigMap{})
+	cm = core.ConfigMap{}
+	err = oCache.Create(CMIdent, cmNN, &cm)
+	assert.NoError(t, err)
+	cm.Data = map[string]string{"key": "desired"}
+	cm.Labels = map[string]string{"app": "drift"}
+	err = oCache.Update(CMIdent, &cm)
+	assert.NoError(t, err)
+	err = oCache.Create(CMIdent, goneNN, &core.ConfigMap{})
+	assert.NoError(t, err)
+
+	SecretIdent := NewSingleResourceIdent("TEST", "DRIFT_SECRET", &core.Secret{})
+	secret = core.Secret{}
+	err = oCache.Create(SecretIdent, secretNN, &secret)
+	assert.NoError(t, err)
+	secret.Data = map[string][]byte{"password": []byte("hunter2")}
+	err = oCache.Update(SecretIdent, &secret)
+	assert.NoError(t, err)
+
+	// An unchanged typed object is not reported, whatever the converter fills in.
+	DepIdent := NewSingleResourceIdent("TEST", "DRIFT_DEPLOYMENT", &apps.Deployment{})
+	dep = apps.Deployment{}
+	err = oCache.Create(DepIdent, depNN, &dep)
+	assert.NoError(t, err)
+	err = oCache.Update(DepIdent, &dep)
+	assert.NoError(t, err)
+
+	err = k8sClient.Delete(ctx, &core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: goneNN.Name, Namespace: goneNN.Namespace}})
+	assert.NoError(t, err)
+
+	drifts, err := oCache.DetectDrift()
+	assert.NoError(t, err)
+	byName := map[string]ObjectDrift{}
+	for _, drift := range drifts {
+		byName[drift.Ref.NamespacedName.Name] = drift
+	}
+	assert.Len(t, byName, 3)
+
+	assert.Equal(t, []FieldDrift{
+		{Path: "data.key", Desired: "desired", Live: "edited"},
+		{Path: "metadata.labels.app", Desired: "drift", Live: nil},
+		{Path: "metadata.labels.edited", Desired: nil, Live: "true"},
+	}, byName[cmNN.Name].Fields)
+
+	assert.Equal(t, []FieldDrift{
+		{Path: "data.password", Desired: redactedValue, Live: redactedValue},
+	}, byName[secretNN.Name].Fields)
+
+	assert.True(t, byName[goneNN.Name].Missing)
+
+	// Nothing was written back.
+	err = k8sClient.Get(ctx, cmNN, &cm)
+	assert.NoError(t, err)
+	assert.Equal(t, "edited", cm.Data["key"])
+}
+
+func TestObjectCachePause(t *testing.T) {
+	ctx := context.Background()
+	const pause = "test.example.com/paused"
+
+	owner := core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pause-owner", Namespace: "default"},
+	}
+	err := k8sClient.Create(ctx, &owner)
+	assert.NoError(t, err)
+	ownerRef := utils.MakeOwnerReference(&owner)
+	ownerRef.APIVersion, ownerRef.Kind = "v1", "ConfigMap"
+
+	pausedNN := types.NamespacedName{Name: "test-pause-paused", Namespace: "default"}
+	activeNN := types.NamespacedName{Name: "test-pause-active", Namespace: "default"}
+	orphanNN := types.NamespacedName{Name: "test-pause-orphan", Namespace: "default"}
+	for _, cm := range []core.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: pausedNN.Name, Namespace: pausedNN.Namespace, Annotations: map[string]string{pause: "INC-123"}, OwnerReferences: []metav1.OwnerReference{ownerRef}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: activeNN.Name, Namespace: activeNN.Namespace, OwnerReferences: []metav1.OwnerReference{ownerRef}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: orphanNN.Name, Namespace: orphanNN.Namespace, OwnerReferences: []metav1.OwnerReference{ownerRef}}},
+	} {
+		err := k8sClient.Create(ctx, &cm)
+		assert.NoError(t, err)
+	}
+
+	possibleGVKs := GVKMap{{Version: "v1", Kind: "ConfigMap"}: true}
+	protectedGVKs := GVKMap{}
+	MultiIdent := NewMultiResourceIdent("TEST", "PAUSE", &core.ConfigMap{})
+
+	newCache := func() *ObjectCache {
+		config := NewCacheConfig(scheme, possibleGVKs, protectedGVKs, Options{Owner: &owner, PauseAnnotation: pause})
+		oCache := NewObjectCache(ctx, k8sClient, &log, config)
+		for _, nn := range []types.NamespacedName{pausedNN, activeNN} {
+			cm := core.ConfigMap{}
+			err := oCache.Create(MultiIdent, nn, &cm)
+			assert.NoError(t, err)
+			cm.Data = map[string]string{"key": "applied"}
+			err = oCache.Update(MultiIdent, &cm)
+			assert.NoError(t, err)
+		}
+		return &oCache
+	}
+
+	// The annotation on a live object pauses only that object.
+	oCache := newCache()
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	result := oCache.LastApplyResult().Clusters[LocalCluster]
+	assert.Equal(t, []ObjectRef{{GVK: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, NamespacedName: pausedNN}}, result.Paused)
+	assert.Len(t, result.Applied, 1)
+
+	cm := core.ConfigMap{}
+	err = k8sClient.Get(ctx, pausedNN, &cm)
+	assert.NoError(t, err)
+	assert.Empty(t, cm.Data)
+	err = k8sClient.Get(ctx, activeNN, &cm)
+	assert.NoError(t, err)
+	assert.Equal(t, "applied", cm.Data["key"])
+
+	// The annotation on the owner pauses everything, including deletes.
+	owner.Annotations = map[string]string{pause: "INC-456"}
+	oCache = newCache()
+	err = oCache.ApplyAll()
+	assert.NoError(t, err)
+	assert.Len(t, oCache.LastApplyResult().Clusters[LocalCluster].Paused, 2)
+
+	err = oCache.Reconcile(owner.UID)
+	assert.NoError(t, err)
+	result = oCache.LastReconcileResult().Clusters[LocalCluster]
+	assert.Empty(t, result.Deleted)
+	assert.Len(t, result.Paused, 1)
+	err = k8sClient.Get(ctx, orphanNN, &cm)
+	assert.NoError(t, err)
+
+	// WriteNow updates of paused objects are not written either.
+	owner.Annotations = nil
+	config := NewCacheConfig(scheme, possibleGVKs, protectedGVKs, Options{Owner: &owner, PauseAnnotation: pause})
+	writeNowCache := NewObjectCache(ctx, k8sClient, &log, config)
+	WriteNowIdent := NewMultiResourceIdent("TEST", "PAUSE_WRITENOW", &core.ConfigMap{}, ResourceOptions{WriteNow: true})
+	cm = core.ConfigMap{}
+	err = writeNowCache.Create(WriteNowIdent, pausedNN, &cm)
+	assert.NoError(t, err)
+	cm.Data = map[string]string{"key": "written now"}
+	err = writeNowCache.Update(WriteNowIdent, &cm)
+	assert.NoError(t, err)
+	err = k8sClient.Get(ctx, pausedNN, &cm)
+	assert.NoError(t, err)
+	assert.Empty(t, cm.Data)
+
+	// A paused object that is not owned is not refused by the adoption policy.
+	unownedNN := types.NamespacedName{Name: "test-pause-unowned", Namespace: "default"}
+	err = k8sClient.Create(ctx, &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: unownedNN.Name, Namespace: unownedNN.Namespace, Annotations: map[string]string{pause: "INC-789"}},
+	})
+	assert.NoError(t, err)
+	config = NewCacheConfig(scheme, possibleGVKs, protectedGVKs, Options{Owner: &owner, PauseAnnotation: pause, AdoptionPolicy: AdoptNever})
+	neverCache := NewObjectCache(ctx, k8sClient, &log, config)
+	cm = core.ConfigMap{}
+	err = neverCache.Create(MultiIdent, unownedNN, &cm)
+	assert.NoError(t, err)
+	cm.Data = map[string]string{"key": "applied"}
+	err = neverCache.Update(MultiIdent, &cm)
+	assert.NoError(t, err)
+	err = neverCache.ApplyAll()
+	assert.NoError(t, err)
+	assert.Len(t, neverCache.LastApplyResult().Clusters[LocalCluster].Paused, 1)
+	err = k8sClient.Get(ctx, unownedNN, &cm)
+	assert.NoError(t, err)
+	assert.Empty(t, cm.Data)
+	assert.Empty(t, cm.OwnerReferences)
+}
@@ -0,0 +1,203 @@
+package resourcecachetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RedHatInsights/go-difflib/difflib"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files with the current output instead of comparing against them")
+
+// Options configures a Harness.
+type Options struct {
+	// Fixtures are paths to YAML or JSON files whose objects are loaded into the fake client before
+	// the cache is created. They represent the live state of the cluster.
+	Fixtures []string
+	// OwnerUID is the owner passed to Reconcile when the planned deletes are worked out.
+	OwnerUID types.UID
+	// PossibleGVKs, ProtectedGVKs and CacheOptions are passed to NewCacheConfig.
+	PossibleGVKs  rc.GVKMap
+	ProtectedGVKs rc.GVKMap
+	CacheOptions  rc.Options
+	// StatusSubresources lists custom resource types that have a status subresource. Built in
+	// types are already handled by the fake client.
+	StatusSubresources []client.Object
+	// Logger is used by the cache, defaulting to a discarding logger.
+	Logger *logr.Logger
+}
+
+// Harness runs providers against an ObjectCache backed by a controller-runtime fake client and
+// compares the result with golden files.
+type Harness struct {
+	Client   client.Client
+	Cache    *rc.ObjectCache
+	ownerUID types.UID
+	t        testing.TB
+}
+
+// New builds a Harness whose fake client is seeded with the objects found in the fixtures. Every
+// object in the fixtures must be of a type registered in the scheme.
+func New(t testing.TB, scheme *runtime.Scheme, opts Options) *Harness {
+	t.Helper()
+
+	var objs []client.Object
+	for _, path := range opts.Fixtures {
+		fixtureObjs, err := loadFixture(scheme, path)
+		if err != nil {
+			t.Fatalf("loading fixture %s: %s", path, err)
+		}
+		objs = append(objs, fixtureObjs...)
+	}
+
+	kclient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(opts.StatusSubresources...).
+		Build()
+
+	config := rc.NewCacheConfig(scheme, opts.PossibleGVKs, opts.ProtectedGVKs, opts.CacheOptions)
+	cache := rc.NewObjectCache(context.Background(), kclient, opts.Logger, config)
+
+	return &Harness{
+		Client:   kclient,
+		Cache:    &cache,
+		ownerUID: opts.OwnerUID,
+		t:        t,
+	}
+}
+
+// Run calls the provider with the harness's cache, failing the test if it returns an error.
+func (h *Harness) Run(provider func(cache *rc.ObjectCache) error) {
+	h.t.Helper()
+	if err := provider(h.Cache); err != nil {
+		h.t.Fatalf("provider returned an error: %s", err)
+	}
+}
+
+// Render returns the planned creates, updates and deletes followed by the cache contents as YAML,
+// with server populated metadata stripped and Secrets redacted.
+func (h *Harness) Render() (string, error) {
+	plan, err := h.Cache.Plan(h.ownerUID)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString("# Plan\n")
+	for _, step := range []struct {
+		verb string
+		refs []rc.ObjectRef
+	}{
+		{"create", plan.Creates},
+		{"update", plan.Updates},
+		{"delete", plan.Deletes},
+	} {
+		for _, ref := range step.refs {
+			fmt.Fprintf(&buf, "%s %s\n", step.verb, ref)
+		}
+	}
+
+	buf.WriteString("\n# Objects\n")
+	if err := h.Cache.Export(&buf, rc.ExportYAML, rc.ExportOptions{StripServerFields: true, RedactSecrets: true}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// AssertGolden compares the rendered cache with the golden file at path. When the test binary is
+// run with -update-golden the file is written instead.
+func (h *Harness) AssertGolden(path string) {
+	h.t.Helper()
+
+	actual, err := h.Render()
+	if err != nil {
+		h.t.Fatalf("rendering cache: %s", err)
+	}
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			h.t.Fatalf("creating golden file directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o600); err != nil {
+			h.t.Fatalf("writing golden file: %s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		h.t.Fatalf("reading golden file (run with -update-golden to create it): %s", err)
+	}
+
+	if string(expected) != actual {
+		diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(expected)),
+			B:        difflib.SplitLines(actual),
+			FromFile: path,
+			ToFile:   "actual",
+			Context:  3,
+		})
+		h.t.Errorf("cache does not match golden file %s (run with -update-golden to accept):\n%s", path, diff)
+	}
+}
+
+// loadFixture decodes every object in a YAML or JSON file into the scheme's typed objects.
+func loadFixture(scheme *runtime.Scheme, path string) ([]client.Object, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+
+	var objs []client.Object
+	for {
+		raw := runtime.RawExtension{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if trimmed := strings.TrimSpace(string(raw.Raw)); trimmed == "" || trimmed == "null" {
+			continue
+		}
+
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(raw.Raw); err != nil {
+			return nil, err
+		}
+
+		typed, err := scheme.New(u.GroupVersionKind())
+		if err != nil {
+			return nil, err
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+			return nil, err
+		}
+		obj, ok := typed.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("kind %s is not a client.Object", u.GroupVersionKind())
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
@@ -0,0 +1,77 @@
+package resourcecachetest
+
+import (
+	"testing"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+}
+
+var (
+	ServiceIdent = rc.NewSingleResourceIdent("web", "service", &core.Service{})
+	ConfigIdent  = rc.NewSingleResourceIdent("web", "config", &core.ConfigMap{})
+	SecretIdent  = rc.NewSingleResourceIdent("web", "secret", &core.Secret{})
+)
+
+var owner = metav1.OwnerReference{
+	APIVersion: "v1",
+	Kind:       "ConfigMap",
+	Name:       "owner",
+	UID:        "5656-5656-5656-5656",
+}
+
+// webProvider is an example provider which updates an existing Service and creates a ConfigMap and
+// a Secret.
+func webProvider(cache *rc.ObjectCache) error {
+	nn := types.NamespacedName{Name: "web", Namespace: "default"}
+
+	svc := &core.Service{}
+	if err := cache.Create(ServiceIdent, nn, svc); err != nil {
+		return err
+	}
+	svc.Spec.Ports[0].Port = 9000
+	if err := cache.Update(ServiceIdent, svc); err != nil {
+		return err
+	}
+
+	cm := &core.ConfigMap{}
+	if err := cache.Create(ConfigIdent, nn, cm); err != nil {
+		return err
+	}
+	cm.Name, cm.Namespace = nn.Name, nn.Namespace
+	cm.OwnerReferences = []metav1.OwnerReference{owner}
+	cm.Data = map[string]string{"port": "9000"}
+	if err := cache.Update(ConfigIdent, cm); err != nil {
+		return err
+	}
+
+	secret := &core.Secret{}
+	if err := cache.Create(SecretIdent, nn, secret); err != nil {
+		return err
+	}
+	secret.Name, secret.Namespace = nn.Name, nn.Namespace
+	secret.OwnerReferences = []metav1.OwnerReference{owner}
+	secret.StringData = map[string]string{"password": "hunter2"}
+	return cache.Update(SecretIdent, secret)
+}
+
+func TestHarnessGolden(t *testing.T) {
+	h := New(t, scheme, Options{
+		Fixtures: []string{"testdata/cluster.yaml"},
+		OwnerUID: owner.UID,
+	})
+
+	h.Run(webProvider)
+	h.AssertGolden("testdata/web.golden")
+}
@@ -0,0 +1,101 @@
+package resourcecache
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SnapshotToken identifies a snapshot taken with ObjectCache.Snapshot.
+type SnapshotToken struct {
+	id uint64
+}
+
+// cacheState is a deep copy of the cache contents held by a snapshot.
+type cacheState struct {
+	data            map[ResourceIdent]map[types.NamespacedName]*k8sResource
+	resourceTracker map[trackerKey]map[types.NamespacedName]bool
+	names           map[ResourceIdentMulti]map[NameKey]types.NamespacedName
+	identOrder      map[ResourceIdent]int
+}
+
+// Snapshot records a deep copy of the cache contents and returns a token that Rollback can later
+// restore them from.
+func (o *ObjectCache) Snapshot() SnapshotToken {
+	if o.snapshots == nil {
+		o.snapshots = make(map[SnapshotToken]*cacheState)
+	}
+	o.snapshotSeq++
+	token := SnapshotToken{id: o.snapshotSeq}
+	o.snapshots[token] = &cacheState{
+		data:            copyData(o.data),
+		resourceTracker: copyTracker(o.resourceTracker),
+		names:           copyNames(o.names),
+		identOrder:      copyIdentOrder(o.identOrder),
+	}
+	return token
+}
+
+// Rollback restores the cache contents recorded by the snapshot, throwing away every Create and
+// Update made since. The token stays valid, so the cache can be rolled back to it again, but
+// snapshots taken after it are discarded. Objects already written to k8s, by WriteNow idents or
+// ApplyAll, are not touched.
+func (o *ObjectCache) Rollback(token SnapshotToken) error {
+	state, ok := o.snapshots[token]
+	if !ok {
+		return fmt.Errorf("cannot rollback: unknown snapshot [%d]", token.id)
+	}
+
+	o.data = copyData(state.data)
+	o.resourceTracker = copyTracker(state.resourceTracker)
+	o.names = copyNames(state.names)
+	o.identOrder = copyIdentOrder(state.identOrder)
+
+	for t := range o.snapshots {
+		if t.id > token.id {
+			delete(o.snapshots, t)
+		}
+	}
+	return nil
+}
+
+func copyData(data map[ResourceIdent]map[types.NamespacedName]*k8sResource) map[ResourceIdent]map[types.NamespacedName]*k8sResource {
+	dataCopy := make(map[ResourceIdent]map[types.NamespacedName]*k8sResource, len(data))
+	for ident, objs := range data {
+		objsCopy := make(map[types.NamespacedName]*k8sResource, len(objs))
+		for nn, res := range objs {
+			objsCopy[nn] = res.deepCopy()
+		}
+		dataCopy[ident] = objsCopy
+	}
+	return dataCopy
+}
+
+func copyTracker(tracker map[trackerKey]map[types.NamespacedName]bool) map[trackerKey]map[types.NamespacedName]bool {
+	trackerCopy := make(map[trackerKey]map[types.NamespacedName]bool, len(tracker))
+	for key, names := range tracker {
+		namesCopy := make(map[types.NamespacedName]bool, len(names))
+		for nn, v := range names {
+			namesCopy[nn] = v
+		}
+		trackerCopy[key] = namesCopy
+	}
+	return trackerCopy
+}
+
+func copyIdentOrder(identOrder map[ResourceIdent]int) map[ResourceIdent]int {
+	orderCopy := make(map[ResourceIdent]int, len(identOrder))
+	for ident, i := range identOrder {
+		orderCopy[ident] = i
+	}
+	return orderCopy
+}
+
+func (r *k8sResource) deepCopy() *k8sResource {
+	resCopy := *r
+	resCopy.Object = r.Object.DeepCopyObject().(client.Object)
+	resCopy.origObject = r.origObject.DeepCopyObject().(client.Object)
+	return &resCopy
+}
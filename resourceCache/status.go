@@ -0,0 +1,112 @@
+package resourcecache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UpdateStatus replaces the cached object, like Update, and marks it for a status update. The
+// status is patched during apply even if nothing outside of the status has changed, in which case
+// the main object write is skipped entirely.
+func (o *ObjectCache) UpdateStatus(resourceIdent ResourceIdent, object client.Object) error {
+	return o.UpdateStatusContext(o.ctx, resourceIdent, object)
+}
+
+// UpdateStatusContext is UpdateStatus using the given context for any immediate write.
+func (o *ObjectCache) UpdateStatusContext(ctx context.Context, resourceIdent ResourceIdent, object client.Object) error {
+	if err := o.Status(resourceIdent, object); err != nil {
+		return err
+	}
+	return o.UpdateContext(ctx, resourceIdent, object)
+}
+
+// objectContent returns the unstructured content of an object, optionally without its status.
+func objectContent(obj client.Object, withoutStatus bool) (map[string]interface{}, error) {
+	content, err := toContent(obj)
+	if err != nil {
+		return nil, err
+	}
+	if withoutStatus {
+		delete(content, "status")
+	}
+	return content, nil
+}
+
+// needsApply reports whether the main object needs writing. When the resource is marked for a
+// status update, the status is handled separately and ignored here.
+func needsApply(res *k8sResource) (bool, error) {
+	if !res.Update {
+		return true, nil
+	}
+	if !res.Status {
+		return !equality.Semantic.DeepEqual(res.origObject, res.Object), nil
+	}
+
+	orig, err := objectContent(res.origObject, true)
+	if err != nil {
+		return false, err
+	}
+	desired, err := objectContent(res.Object, true)
+	if err != nil {
+		return false, err
+	}
+	return !equality.Semantic.DeepEqual(orig, desired), nil
+}
+
+// statusChanged reports whether a resource marked for a status update has a status that differs
+// from the one fetched at Create.
+func statusChanged(res *k8sResource) (bool, error) {
+	if !res.Status {
+		return false, nil
+	}
+	orig, err := toContent(res.origObject)
+	if err != nil {
+		return false, err
+	}
+	desired, err := toContent(res.Object)
+	if err != nil {
+		return false, err
+	}
+	return !equality.Semantic.DeepEqual(orig["status"], desired["status"]), nil
+}
+
+// applyStatus patches the status subresource of an object so that it matches desired. The live
+// object is the state last returned by the server; only the difference between the two statuses is
+// sent, so fields owned by other writers are left alone.
+func (o *ObjectCache) applyStatus(ctx context.Context, kclient client.Client, res *k8sResource, live, desired client.Object) error {
+	liveContent, err := toContent(live)
+	if err != nil {
+		return err
+	}
+	desiredContent, err := toContent(desired)
+	if err != nil {
+		return err
+	}
+	if equality.Semantic.DeepEqual(liveContent["status"], desiredContent["status"]) {
+		return nil
+	}
+
+	patched := reflect.New(reflect.TypeOf(live).Elem()).Interface().(client.Object)
+	if status, ok := desiredContent["status"]; ok {
+		liveContent["status"] = status
+	} else {
+		delete(liveContent, "status")
+	}
+	if err := fromContent(liveContent, patched); err != nil {
+		return err
+	}
+
+	if err := o.throttle(ctx, "patch status"); err != nil {
+		return err
+	}
+	if err := kclient.Status().Patch(ctx, patched, client.MergeFrom(live)); err != nil {
+		return fmt.Errorf("error patching status of %s %s: %w", patched.GetObjectKind().GroupVersionKind().Kind, patched.GetName(), err)
+	}
+	res.Object = patched
+	return nil
+}
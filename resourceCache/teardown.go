@@ -0,0 +1,220 @@
+package resourcecache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TeardownResult reports the progress of Teardown.
+type TeardownResult struct {
+	// Done is set once every owned object is gone and the finalizer has been removed from the
+	// owner.
+	Done bool
+	// Tier is the place in Options.Ordering of the GVKs being torn down.
+	Tier int
+	// Deleted lists the objects Teardown asked k8s to delete in this call.
+	Deleted []ObjectRef
+	// Remaining lists the objects of the current tier that have not gone yet, including those
+	// still being deleted.
+	Remaining []ObjectRef
+}
+
+type teardownObject struct {
+	cluster string
+	obj     unstructured.Unstructured
+}
+
+// Teardown removes everything the owner owns, in the reverse of the apply ordering, and then
+// removes the finalizer from the owner. It is meant to be called each time a deleted owner is
+// reconciled: each call deletes the objects in the last tier of Options.Ordering that still has
+// owned objects and returns without waiting. Once a tier has gone, including objects held back by
+// foreground deletion, the next call moves on to the tier before it. When nothing owned is left
+// the finalizer is removed from the owner and Done is set; until then the reconciler should
+// requeue. Objects of protected GVKs are left alone, and the list options narrow the search in
+// the same way as for Reconcile.
+//
+// Only the possible GVKs, and those of the objects a ChangeTracker remembers for the owner, are
+// searched, so the config must list every GVK the providers create. An error is returned when
+// there is nothing to search, rather than removing the finalizer with owned objects left behind.
+func (o *ObjectCache) Teardown(owner client.Object, finalizer string, opts ...client.ListOption) (TeardownResult, error) {
+	return o.TeardownContext(o.ctx, owner, finalizer, opts...)
+}
+
+// TeardownContext is Teardown using the given context, bounded by Timeouts.Reconcile if set.
+func (o *ObjectCache) TeardownContext(ctx context.Context, owner client.Object, finalizer string, opts ...client.ListOption) (TeardownResult, error) {
+	ctx, cancel := withTimeout(ctx, o.config.options.Timeouts.Reconcile)
+	defer cancel()
+
+	result := TeardownResult{}
+
+	if err := o.config.ordering.err; err != nil {
+		return result, err
+	}
+
+	if owner.GetUID() == "" {
+		return result, fmt.Errorf("cannot teardown: owner [%s/%s] has no UID", owner.GetNamespace(), owner.GetName())
+	}
+
+	gvks := o.teardownGVKs(owner.GetUID())
+	if len(gvks) == 0 {
+		return result, fmt.Errorf("cannot teardown [%s/%s]: no possible GVKs to search for owned objects", owner.GetNamespace(), owner.GetName())
+	}
+
+	tiers, err := o.ownedByTier(ctx, owner.GetUID(), gvks, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	if len(tiers) == 0 {
+		if err := o.removeFinalizer(ctx, owner, finalizer); err != nil {
+			return result, err
+		}
+		if tracker := o.config.options.ChangeTracker; tracker != nil {
+			tracker.Forget(owner.GetUID())
+		}
+		if history := o.config.options.History; history != nil {
+			history.Forget(owner.GetUID())
+		}
+		result.Done = true
+		return result, nil
+	}
+
+	// GVKs matching no pattern of the ordering have a tier of -1, and are applied first, so torn down
+	// last.
+	last := -1
+	for tier := range tiers {
+		if tier > last {
+			last = tier
+		}
+	}
+	result.Tier = last
+
+	for _, v := range tiers[last] {
+		ref := ObjectRef{
+			Cluster:        v.cluster,
+			GVK:            v.obj.GroupVersionKind(),
+			NamespacedName: types.NamespacedName{Namespace: v.obj.GetNamespace(), Name: v.obj.GetName()},
+		}
+		result.Remaining = append(result.Remaining, ref)
+		if v.obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		kclient, err := o.clientFor(v.cluster)
+		if err != nil {
+			return result, err
+		}
+		o.log.Info("TEARDOWN resource ", "namespace", ref.NamespacedName.Namespace, "name", ref.NamespacedName.Name, "kind", ref.GVK.Kind, "cluster", v.cluster, "tier", last)
+		if err := o.throttle(ctx, "delete"); err != nil {
+			return result, err
+		}
+		if err := kclient.Delete(ctx, &v.obj, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil {
+			if k8serr.IsNotFound(err) {
+				continue
+			}
+			return result, fmt.Errorf("[%s]: %w", ref, err)
+		}
+		result.Deleted = append(result.Deleted, ref)
+	}
+	return result, nil
+}
+
+// teardownGVKs returns the non protected GVKs Teardown searches: the possible GVKs, and those of the
+// objects the ChangeTracker remembers for the owner.
+func (o *ObjectCache) teardownGVKs(ownedUID types.UID) []schema.GroupVersionKind {
+	set := make(map[schema.GroupVersionKind]bool)
+	for gvk := range o.config.possibleGVKs {
+		set[gvk] = true
+	}
+	if tracker := o.config.options.ChangeTracker; tracker != nil {
+		for gvk := range tracker.gvks(ownedUID) {
+			set[gvk] = true
+		}
+	}
+
+	var gvks []schema.GroupVersionKind
+	for gvk := range set {
+		if _, ok := o.config.protectedGVKs[gvk]; !ok {
+			gvks = append(gvks, gvk)
+		}
+	}
+	return gvks
+}
+
+// ownedByTier lists every object of the given GVKs owned by ownedUID in the reconciled clusters,
+// grouped by the tier of its GVK in Options.Ordering.
+func (o *ObjectCache) ownedByTier(ctx context.Context, ownedUID types.UID, gvks []schema.GroupVersionKind, opts ...client.ListOption) (map[int][]teardownObject, error) {
+	tiers := make(map[int][]teardownObject)
+
+	for _, cluster := range o.reconciledClusters() {
+		kclient, err := o.clientFor(cluster)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, gvk := range gvks {
+			nobjList := unstructured.UnstructuredList{}
+			nobjList.SetGroupVersionKind(gvk)
+			if err := kclient.List(ctx, &nobjList, opts...); err != nil {
+				return nil, clusterError(cluster, err)
+			}
+
+			tier := o.config.ordering.tier(gvk)
+			for _, obj := range nobjList.Items {
+				if o.owned(&obj, ownedUID) {
+					tiers[tier] = append(tiers[tier], teardownObject{cluster: cluster, obj: obj})
+				}
+			}
+		}
+	}
+
+	for _, objs := range tiers {
+		sort.SliceStable(objs, func(i, j int) bool {
+			a, b := objs[i], objs[j]
+			if a.cluster != b.cluster {
+				return a.cluster < b.cluster
+			}
+			if a.obj.GetKind() != b.obj.GetKind() {
+				return a.obj.GetKind() < b.obj.GetKind()
+			}
+			if a.obj.GetNamespace() != b.obj.GetNamespace() {
+				return a.obj.GetNamespace() < b.obj.GetNamespace()
+			}
+			return a.obj.GetName() < b.obj.GetName()
+		})
+	}
+	return tiers, nil
+}
+
+// removeFinalizer removes the finalizer from the owner, writing it only if the finalizer was set.
+// The owner is patched rather than updated, so a stale copy, for instance one whose status changed
+// while the owned objects were deleted, does not fail with a conflict.
+func (o *ObjectCache) removeFinalizer(ctx context.Context, owner client.Object, finalizer string) error {
+	finalizers := owner.GetFinalizers()
+	kept := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		if f != finalizer {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == len(finalizers) {
+		return nil
+	}
+
+	o.log.Info("Removing finalizer", "namespace", owner.GetNamespace(), "name", owner.GetName(), "finalizer", finalizer)
+	orig := owner.DeepCopyObject().(client.Object)
+	owner.SetFinalizers(kept)
+	if err := o.throttle(ctx, "patch"); err != nil {
+		return err
+	}
+	return o.client.Patch(ctx, owner, client.MergeFrom(orig))
+}
@@ -0,0 +1,129 @@
+package resourcecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RollbackResult records how the writes of a failed transactional ApplyAll were undone.
+type RollbackResult struct {
+	// Reverted lists the updated objects that were restored to their state before ApplyAll.
+	Reverted []ObjectRef
+	// Deleted lists the objects created by ApplyAll that were removed again.
+	Deleted []ObjectRef
+	// Failed lists the objects that could not be restored, with the reasons joined in Err.
+	Failed []ObjectRef
+	Err    error
+}
+
+// journalEntry records a write made by a transactional ApplyAll so that it can be undone.
+type journalEntry struct {
+	ref         ObjectRef
+	propagation metav1.DeletionPropagation
+	// orig is the live object before ApplyAll, applied the object as returned by the last write.
+	orig    client.Object
+	applied client.Object
+	// created is set when the object did not exist before, recreated when it was deleted, and
+	// possibly not created again, because an immutable field changed.
+	created   bool
+	recreated bool
+	// mainWritten and statusWritten record which parts of an existing object were written.
+	mainWritten   bool
+	statusWritten bool
+}
+
+// newJournalEntry captures the state of a resource before it is applied.
+func newJournalEntry(ref ObjectRef, ident ResourceIdent, res *k8sResource) (journalEntry, error) {
+	status, err := statusChanged(res)
+	if err != nil {
+		return journalEntry{}, err
+	}
+	return journalEntry{
+		ref:           ref,
+		propagation:   identImmutablePolicy(ident).Propagation,
+		orig:          res.origObject.DeepCopyObject().(client.Object),
+		created:       !bool(res.Update),
+		statusWritten: status && bool(res.Update),
+	}, nil
+}
+
+// rollback undoes the journalled writes in reverse order. It carries on past failures so that as
+// much as possible is restored, and ignores cancellation of the apply's context.
+func (o *ObjectCache) rollback(ctx context.Context, cluster string, journal []journalEntry) *RollbackResult {
+	ctx, cancel := withTimeout(context.WithoutCancel(ctx), o.config.options.Timeouts.ApplyAll)
+	defer cancel()
+
+	result := &RollbackResult{}
+	kclient, err := o.clientFor(cluster)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var errs []error
+	for i := len(journal) - 1; i >= 0; i-- {
+		entry := journal[i]
+		o.log.Info("ROLLBACK resource ", "namespace", entry.ref.NamespacedName.Namespace, "name", entry.ref.NamespacedName.Name, "kind", entry.ref.GVK.Kind, "cluster", cluster, "created", entry.created)
+
+		if err := o.undo(ctx, kclient, entry); err != nil {
+			result.Failed = append(result.Failed, entry.ref)
+			errs = append(errs, fmt.Errorf("[%s]: %w", entry.ref, err))
+			continue
+		}
+		if entry.created {
+			result.Deleted = append(result.Deleted, entry.ref)
+		} else {
+			result.Reverted = append(result.Reverted, entry.ref)
+		}
+	}
+	result.Err = errors.Join(errs...)
+	return result
+}
+
+// undo reverses a single journalled write.
+func (o *ObjectCache) undo(ctx context.Context, kclient client.Client, entry journalEntry) error {
+	switch {
+	case entry.created:
+		if err := o.throttle(ctx, "delete"); err != nil {
+			return err
+		}
+		if err := kclient.Delete(ctx, entry.applied); err != nil && !k8serr.IsNotFound(err) {
+			return err
+		}
+		return nil
+
+	case entry.recreated:
+		res := &k8sResource{
+			Object:     entry.orig.DeepCopyObject().(client.Object),
+			origObject: entry.applied,
+		}
+		return o.recreate(ctx, kclient, res, entry.propagation)
+	}
+
+	live := entry.applied
+	if entry.mainWritten {
+		reverted := entry.orig.DeepCopyObject().(client.Object)
+		reverted.SetResourceVersion(entry.applied.GetResourceVersion())
+		if err := o.throttle(ctx, "update"); err != nil {
+			return err
+		}
+		if err := kclient.Update(ctx, reverted); err != nil {
+			return err
+		}
+		live = reverted
+	}
+
+	if entry.statusWritten {
+		res := &k8sResource{Object: live}
+		if err := o.applyStatus(ctx, kclient, res, live, entry.orig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package resourcecache
+
+import (
+	"fmt"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NewSingleUnstructuredResourceIdent returns a ResourceIdentSingle for objects of the given GVK held
+// as *unstructured.Unstructured, for kinds that have no Go type registered in the scheme.
+func NewSingleUnstructuredResourceIdent(provider string, purpose string, gvk schema.GroupVersionKind, opts ...ResourceOptions) ResourceIdentSingle {
+	return NewSingleResourceIdent(provider, purpose, newUnstructured(gvk), opts...)
+}
+
+// NewMultiUnstructuredResourceIdent returns a ResourceIdentMulti for objects of the given GVK held
+// as *unstructured.Unstructured, for kinds that have no Go type registered in the scheme.
+func NewMultiUnstructuredResourceIdent(provider string, purpose string, gvk schema.GroupVersionKind, opts ...ResourceOptions) ResourceIdentMulti {
+	return NewMultiResourceIdent(provider, purpose, newUnstructured(gvk), opts...)
+}
+
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	return u
+}
+
+// gvkFor returns the GVK of an object. Typed objects are looked up in the scheme, unstructured
+// objects carry their own GVK, with a missing version resolved through the RESTMapper.
+func (o *ObjectCache) gvkFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		gvk := u.GetObjectKind().GroupVersionKind()
+		if gvk.Kind == "" {
+			return gvk, fmt.Errorf("unstructured object has no kind")
+		}
+		if gvk.Version == "" {
+			if o.mapper == nil {
+				return gvk, fmt.Errorf("unstructured object of kind %s has no version", gvk.Kind)
+			}
+			mapping, err := o.mapper.RESTMapping(gvk.GroupKind())
+			if err != nil {
+				return gvk, err
+			}
+			gvk = mapping.GroupVersionKind
+		}
+		return gvk, nil
+	}
+	return utils.GetKindFromObj(o.scheme, obj)
+}
+
+// checkScope verifies that a namespace is given for namespaced kinds, and is not given for cluster
+// scoped ones. Kinds the RESTMapper does not know are not checked.
+func (o *ObjectCache) checkScope(gvk schema.GroupVersionKind, nn types.NamespacedName) error {
+	namespaced, known, err := o.namespaced(gvk)
+	if err != nil || !known {
+		return err
+	}
+	if namespaced && nn.Namespace == "" {
+		return fmt.Errorf("kind %s is namespaced but [%s] has no namespace", gvk, nn)
+	}
+	if !namespaced && nn.Namespace != "" {
+		return fmt.Errorf("kind %s is cluster scoped but [%s] has a namespace", gvk, nn)
+	}
+	return nil
+}
+
+// namespaced reports whether the kind is namespaced, according to the RESTMapper. known is false if
+// there is no RESTMapper or it has no mapping for the kind.
+func (o *ObjectCache) namespaced(gvk schema.GroupVersionKind) (namespaced bool, known bool, err error) {
+	if o.mapper == nil {
+		return false, false, nil
+	}
+	mapping, err := o.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, true, nil
+}
+
+// toContent returns the unstructured content of an object. The content of unstructured objects is
+// copied, so the result can always be modified without touching the object.
+func toContent(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return runtime.DeepCopyJSON(u.UnstructuredContent()), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+// fromContent populates a typed or unstructured object from unstructured content.
+func fromContent(content map[string]interface{}, obj runtime.Object) error {
+	if u, ok := obj.(runtime.Unstructured); ok {
+		u.SetUnstructuredContent(runtime.DeepCopyJSON(content))
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(content, obj)
+}
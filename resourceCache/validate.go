@@ -0,0 +1,279 @@
+package resourcecache
+
+import (
+	"fmt"
+	"strings"
+
+	apps "k8s.io/api/apps/v1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Validator checks a cached object of a single GVK and returns every problem it finds.
+type Validator func(obj client.Object) field.ErrorList
+
+// DefaultValidators checks the parts of common built in kinds that are most often got wrong: data
+// keys, ports, and pod template labels and containers. Every check calls the upstream apimachinery
+// validators, so the limits and messages match the API server's. The validators only inspect typed
+// objects.
+var DefaultValidators = map[schema.GroupVersionKind][]Validator{
+	{Version: "v1", Kind: "ConfigMap"}:                  {validateConfigMap},
+	{Version: "v1", Kind: "Secret"}:                     {validateSecret},
+	{Version: "v1", Kind: "Service"}:                    {validateService},
+	{Group: "apps", Version: "v1", Kind: "Deployment"}:  {validateDeployment},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"}: {validateStatefulSet},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"}:   {validateDaemonSet},
+	{Group: "batch", Version: "v1", Kind: "Job"}:        {validateJob},
+	{Group: "batch", Version: "v1", Kind: "CronJob"}:    {validateCronJob},
+}
+
+// nameValidators holds the name rules of kinds that do not use DNS subdomain names.
+var nameValidators = map[schema.GroupKind]apivalidation.ValidateNameFunc{
+	{Kind: "Service"}:   apivalidation.NameIsDNS1035Label,
+	{Kind: "Namespace"}: apivalidation.ValidateNamespaceName,
+}
+
+// nameValidator returns the name rule of a kind, which is a DNS subdomain unless listed in
+// nameValidators.
+func nameValidator(gk schema.GroupKind) apivalidation.ValidateNameFunc {
+	if nameFn, ok := nameValidators[gk]; ok {
+		return nameFn
+	}
+	return apivalidation.NameIsDNSSubdomain
+}
+
+// ObjectErrors holds the problems found in a single object.
+type ObjectErrors struct {
+	Ref    ObjectRef
+	Errors field.ErrorList
+}
+
+// ValidationError is returned by Validate, and by ApplyAll before anything is written, when cached
+// objects are invalid. It holds every problem found.
+type ValidationError struct {
+	Objects []ObjectErrors
+}
+
+func (e *ValidationError) Error() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "validation failed for %d objects", len(e.Objects))
+	for _, obj := range e.Objects {
+		for _, err := range obj.Errors {
+			fmt.Fprintf(&b, "\n%s: %s", obj.Ref, err)
+		}
+	}
+	return b.String()
+}
+
+// Validate checks every object ApplyAll would write, returning a *ValidationError listing all the
+// problems found. Metadata is checked with the upstream apimachinery validators, followed by the
+// validators registered for the object's GVK in Options.Validators. ApplyAll runs Validate before
+// writing anything unless Options.SkipValidation is set.
+func (o *ObjectCache) Validate() error {
+	validationErr := &ValidationError{}
+
+	for _, v := range o.sortedObjects().objs {
+		if v.Ident.GetWriteNow() {
+			continue
+		}
+		dirty, err := isDirty(v.Resource)
+		if err != nil {
+			return err
+		}
+		if _, paused := o.paused(v.Resource.origObject); !dirty || paused {
+			continue
+		}
+
+		ref, err := o.refFor(v.Ident, v.NamespacedName, v.Resource.Object)
+		if err != nil {
+			return err
+		}
+		errs, err := o.validateObject(ref.GVK, v.Resource.Object)
+		if err != nil {
+			return err
+		}
+		if len(errs) > 0 {
+			validationErr.Objects = append(validationErr.Objects, ObjectErrors{Ref: ref, Errors: errs})
+		}
+	}
+
+	if len(validationErr.Objects) == 0 {
+		return nil
+	}
+	return validationErr
+}
+
+func (o *ObjectCache) validateObject(gvk schema.GroupVersionKind, obj client.Object) (field.ErrorList, error) {
+	namespaced, known, err := o.namespaced(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		namespaced = obj.GetNamespace() != ""
+	}
+
+	errs := apivalidation.ValidateObjectMetaAccessor(obj, namespaced, nameValidator(gvk.GroupKind()), field.NewPath("metadata"))
+	for _, validator := range o.config.options.Validators[gvk] {
+		errs = append(errs, validator(obj)...)
+	}
+	return errs, nil
+}
+
+func validateConfigMap(obj client.Object) field.ErrorList {
+	cm, ok := obj.(*core.ConfigMap)
+	if !ok {
+		return nil
+	}
+	var errs field.ErrorList
+	for key := range cm.Data {
+		errs = append(errs, validateDataKey(key, field.NewPath("data"))...)
+	}
+	for key := range cm.BinaryData {
+		errs = append(errs, validateDataKey(key, field.NewPath("binaryData"))...)
+		if _, ok := cm.Data[key]; ok {
+			errs = append(errs, field.Invalid(field.NewPath("binaryData").Key(key), key, "duplicate of key present in data"))
+		}
+	}
+	return errs
+}
+
+func validateSecret(obj client.Object) field.ErrorList {
+	secret, ok := obj.(*core.Secret)
+	if !ok {
+		return nil
+	}
+	var errs field.ErrorList
+	for key := range secret.Data {
+		errs = append(errs, validateDataKey(key, field.NewPath("data"))...)
+	}
+	for key := range secret.StringData {
+		errs = append(errs, validateDataKey(key, field.NewPath("stringData"))...)
+	}
+	return errs
+}
+
+func validateDataKey(key string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsConfigMapKey(key) {
+		errs = append(errs, field.Invalid(fldPath, key, msg))
+	}
+	return errs
+}
+
+func validateService(obj client.Object) field.ErrorList {
+	svc, ok := obj.(*core.Service)
+	if !ok {
+		return nil
+	}
+	var errs field.ErrorList
+	portsPath := field.NewPath("spec", "ports")
+	for i, port := range svc.Spec.Ports {
+		idxPath := portsPath.Index(i)
+		if len(svc.Spec.Ports) > 1 && port.Name == "" {
+			errs = append(errs, field.Required(idxPath.Child("name"), "required when there is more than one port"))
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.Port)) {
+			errs = append(errs, field.Invalid(idxPath.Child("port"), port.Port, msg))
+		}
+		if port.NodePort != 0 {
+			for _, msg := range validation.IsValidPortNum(int(port.NodePort)) {
+				errs = append(errs, field.Invalid(idxPath.Child("nodePort"), port.NodePort, msg))
+			}
+		}
+	}
+	return errs
+}
+
+func validateDeployment(obj client.Object) field.ErrorList {
+	d, ok := obj.(*apps.Deployment)
+	if !ok {
+		return nil
+	}
+	return validatePodTemplate(&d.Spec.Template, field.NewPath("spec", "template"))
+}
+
+func validateStatefulSet(obj client.Object) field.ErrorList {
+	ss, ok := obj.(*apps.StatefulSet)
+	if !ok {
+		return nil
+	}
+	return validatePodTemplate(&ss.Spec.Template, field.NewPath("spec", "template"))
+}
+
+func validateDaemonSet(obj client.Object) field.ErrorList {
+	ds, ok := obj.(*apps.DaemonSet)
+	if !ok {
+		return nil
+	}
+	return validatePodTemplate(&ds.Spec.Template, field.NewPath("spec", "template"))
+}
+
+func validateJob(obj client.Object) field.ErrorList {
+	job, ok := obj.(*batch.Job)
+	if !ok {
+		return nil
+	}
+	return validatePodTemplate(&job.Spec.Template, field.NewPath("spec", "template"))
+}
+
+func validateCronJob(obj client.Object) field.ErrorList {
+	cj, ok := obj.(*batch.CronJob)
+	if !ok {
+		return nil
+	}
+	var errs field.ErrorList
+	if cj.Spec.Schedule == "" {
+		errs = append(errs, field.Required(field.NewPath("spec", "schedule"), ""))
+	}
+	return append(errs, validatePodTemplate(&cj.Spec.JobTemplate.Spec.Template, field.NewPath("spec", "jobTemplate", "spec", "template"))...)
+}
+
+// validatePodTemplate checks that a pod template has valid labels and containers, and that they are
+// named uniquely, have an image and expose valid ports.
+func validatePodTemplate(template *core.PodTemplateSpec, fldPath *field.Path) field.ErrorList {
+	errs := metav1validation.ValidateLabels(template.Labels, fldPath.Child("metadata", "labels"))
+	containersPath := fldPath.Child("spec", "containers")
+	if len(template.Spec.Containers) == 0 {
+		errs = append(errs, field.Required(containersPath, ""))
+	}
+
+	names := sets.New[string]()
+	errs = append(errs, validateContainers(template.Spec.InitContainers, fldPath.Child("spec", "initContainers"), names)...)
+	errs = append(errs, validateContainers(template.Spec.Containers, containersPath, names)...)
+	return errs
+}
+
+func validateContainers(containers []core.Container, fldPath *field.Path, names sets.Set[string]) field.ErrorList {
+	var errs field.ErrorList
+	for i, container := range containers {
+		idxPath := fldPath.Index(i)
+		if container.Name == "" {
+			errs = append(errs, field.Required(idxPath.Child("name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(container.Name) {
+				errs = append(errs, field.Invalid(idxPath.Child("name"), container.Name, msg))
+			}
+			if names.Has(container.Name) {
+				errs = append(errs, field.Duplicate(idxPath.Child("name"), container.Name))
+			}
+			names.Insert(container.Name)
+		}
+		if container.Image == "" {
+			errs = append(errs, field.Required(idxPath.Child("image"), ""))
+		}
+		for j, port := range container.Ports {
+			for _, msg := range validation.IsValidPortNum(int(port.ContainerPort)) {
+				errs = append(errs, field.Invalid(idxPath.Child("ports").Index(j).Child("containerPort"), port.ContainerPort, msg))
+			}
+		}
+	}
+	return errs
+}
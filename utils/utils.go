@@ -14,6 +14,7 @@ import (
 	"github.com/go-logr/logr"
 	core "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -431,3 +432,20 @@ func UpdateLabels(obj MetaMutator, desiredLabels ...map[string]string) {
 
 	obj.SetLabels(labels)
 }
+
+// MergeConditions merges the given conditions into a copy of existing, matching them by type. The
+// LastTransitionTime of an existing condition is only moved when its status changes, taking the
+// value from the new condition if set or the current time if not. New conditions without a
+// LastTransitionTime get the current time. The existing slice is not modified.
+func MergeConditions(existing []metav1.Condition, conditions ...metav1.Condition) []metav1.Condition {
+	merged := make([]metav1.Condition, 0, len(existing)+len(conditions))
+	for _, condition := range existing {
+		merged = append(merged, *condition.DeepCopy())
+	}
+
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&merged, condition)
+	}
+
+	return merged
+}
@@ -3,10 +3,13 @@ package utils
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -214,3 +217,51 @@ func TestMetaMutatorLabelsMulti(t *testing.T) {
 
 	assert.Equal(t, expected, b.GetLabels())
 }
+
+func TestMergeConditions(t *testing.T) {
+	then := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	existing := []metav1.Condition{{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		Reason:             "Deployed",
+		LastTransitionTime: then,
+	}, {
+		Type:               "Degraded",
+		Status:             metav1.ConditionFalse,
+		Reason:             "Healthy",
+		LastTransitionTime: then,
+	}}
+
+	merged := MergeConditions(existing, metav1.Condition{
+		Type:    "Ready",
+		Status:  metav1.ConditionTrue,
+		Reason:  "StillDeployed",
+		Message: "all good",
+	}, metav1.Condition{
+		Type:   "Degraded",
+		Status: metav1.ConditionTrue,
+		Reason: "PodsFailing",
+	}, metav1.Condition{
+		Type:   "Paused",
+		Status: metav1.ConditionFalse,
+		Reason: "NotPaused",
+	})
+
+	assert.Len(t, merged, 3)
+
+	ready := meta.FindStatusCondition(merged, "Ready")
+	assert.Equal(t, "StillDeployed", ready.Reason)
+	assert.Equal(t, "all good", ready.Message)
+	assert.Equal(t, then, ready.LastTransitionTime, "unchanged status should keep its transition time")
+
+	degraded := meta.FindStatusCondition(merged, "Degraded")
+	assert.Equal(t, metav1.ConditionTrue, degraded.Status)
+	assert.True(t, degraded.LastTransitionTime.After(then.Time), "changed status should move its transition time")
+
+	paused := meta.FindStatusCondition(merged, "Paused")
+	assert.False(t, paused.LastTransitionTime.IsZero())
+
+	assert.Equal(t, "Deployed", existing[0].Reason, "existing conditions should not be modified")
+	assert.Equal(t, metav1.ConditionFalse, existing[1].Status, "existing conditions should not be modified")
+}